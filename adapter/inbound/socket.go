@@ -9,8 +9,30 @@ import (
 	"github.com/Dreamacro/clash/transport/socks5"
 )
 
+// Option sets optional fields on the Metadata NewSocket (and the sibling
+// HTTP/HTTPS constructors) produce, for listener-specific behavior that
+// doesn't fit the target/conn/source parameters every inbound shares.
+type Option func(metadata *C.Metadata)
+
+// WithInName stamps the name of the inbound listener a connection arrived
+// on onto its Metadata.
+func WithInName(name string) Option {
+	return func(metadata *C.Metadata) {
+		metadata.InName = name
+	}
+}
+
+// WithSpecialRules stamps the name of a sub-rules group (configured under
+// the top-level sub-rules: map) that should be evaluated ahead of the main
+// rule list for this connection.
+func WithSpecialRules(name string) Option {
+	return func(metadata *C.Metadata) {
+		metadata.PreferRulesName = name
+	}
+}
+
 // NewSocket receive TCP inbound and return ConnContext
-func NewSocket(target interface{}, conn net.Conn, source C.Type) *context.ConnContext {
+func NewSocket(target interface{}, conn net.Conn, source C.Type, opts ...Option) *context.ConnContext {
 	var metadata *C.Metadata
 	if addr, ok := target.(socks5.Addr); ok {
 		metadata = parseSocks5Addr(addr)
@@ -25,5 +47,9 @@ func NewSocket(target interface{}, conn net.Conn, source C.Type) *context.ConnCo
 		metadata.SrcPort = port
 	}
 
+	for _, opt := range opts {
+		opt(metadata)
+	}
+
 	return context.NewConnContext(conn, metadata)
 }
@@ -60,9 +60,14 @@ func (ssr *ShadowSocksR) StreamConn(c net.Conn, metadata *C.Metadata) (net.Conn,
 
 // DialContext implements C.ProxyAdapter
 func (ssr *ShadowSocksR) DialContext(ctx context.Context, metadata *C.Metadata) (_ C.Conn, err error) {
-	c, err := dialer.DialContext(ctx, "tcp", ssr.addr)
+	addr, err := resolveProxyServerAddr(ssr.addr)
 	if err != nil {
-		return nil, fmt.Errorf("%s connect error: %w", ssr.addr, err)
+		return nil, fmt.Errorf("%s resolve proxy server error: %w", ssr.addr, err)
+	}
+
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s connect error: %w", addr, err)
 	}
 	tcpKeepAlive(c)
 
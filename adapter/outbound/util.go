@@ -0,0 +1,26 @@
+package outbound
+
+import (
+	"net"
+
+	"github.com/Dreamacro/clash/component/resolver"
+)
+
+// resolveProxyServerAddr resolves the host part of a "host:port" proxy
+// server address through the dedicated ProxyServer nameserver group,
+// instead of whatever resolver user traffic goes through (fake-ip, a
+// tun-redirected DNS, ...), so dialing the proxy itself can't deadlock on
+// resolving the very proxy it's supposed to tunnel through.
+func resolveProxyServerAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+
+	ip, err := resolver.ResolveProxyServerHost(host)
+	if err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(ip.String(), port), nil
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/Dreamacro/clash/component/dialer"
 	"github.com/Dreamacro/clash/component/resolver"
 	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/transport/grpc"
+	"github.com/Dreamacro/clash/transport/plugin"
 	"github.com/Dreamacro/clash/transport/vless"
 	"github.com/Dreamacro/clash/transport/vmess"
 	xtls "github.com/xtls/go"
@@ -35,23 +37,31 @@ type Vless struct {
 
 type VlessOption struct {
 	BasicOption
-	Name           string    `proxy:"name"`
-	Server         string    `proxy:"server"`
-	Port           int       `proxy:"port"`
-	UUID           string    `proxy:"uuid"`
-	UDP            bool      `proxy:"udp,omitempty"`
-	Network        string    `proxy:"network,omitempty"`
-	Flow           string    `proxy:"flow,omitempty"`
-	TLS            bool      `proxy:"tls,omitempty"`
-	SkipCertVerify bool      `proxy:"skip-cert-verify,omitempty"`
-	ServerName     string    `proxy:"servername,omitempty"`
-	WSOpts         WSOptions `proxy:"ws-opts,omitempty"`
+	Name           string                 `proxy:"name"`
+	Server         string                 `proxy:"server"`
+	Port           int                    `proxy:"port"`
+	UUID           string                 `proxy:"uuid"`
+	UDP            bool                   `proxy:"udp,omitempty"`
+	Network        string                 `proxy:"network,omitempty"`
+	Flow           string                 `proxy:"flow,omitempty"`
+	TLS            bool                   `proxy:"tls,omitempty"`
+	SkipCertVerify bool                   `proxy:"skip-cert-verify,omitempty"`
+	ServerName     string                 `proxy:"servername,omitempty"`
+	WSOpts         WSOptions              `proxy:"ws-opts,omitempty"`
+	GRPCOpts       GRPCOptions            `proxy:"grpc-opts,omitempty"`
+	Plugin         string                 `proxy:"plugin,omitempty"`
+	PluginOpts     map[string]interface{} `proxy:"plugin-opts,omitempty"`
 
 	// TODO: remove these until 2022
 	WSHeaders map[string]string `proxy:"ws-headers,omitempty"`
 	WSPath    string            `proxy:"ws-path,omitempty"`
 }
 
+// GRPCOptions configures the "grpc" network for a Vless outbound.
+type GRPCOptions struct {
+	GRPCServiceName string `proxy:"grpc-service-name,omitempty"`
+}
+
 func (v *Vless) StreamConn(c net.Conn, metadata *C.Metadata) (net.Conn, error) {
 	var err error
 	switch v.option.Network {
@@ -94,7 +104,28 @@ func (v *Vless) StreamConn(c net.Conn, metadata *C.Metadata) (net.Conn, error) {
 			}
 		}
 		c, err = vmess.StreamWebsocketConn(c, wsOpts)
+	case "grpc":
+		host, port, _ := net.SplitHostPort(v.addr)
+		gunConfig := &grpc.GRPCConfig{
+			Host:        host,
+			Port:        port,
+			ServiceName: v.option.GRPCOpts.GRPCServiceName,
+		}
+
+		if v.option.TLS {
+			gunConfig.TLS = true
+			gunConfig.SkipCertVerify = v.option.SkipCertVerify
+			if v.option.ServerName != "" {
+				gunConfig.ServerName = v.option.ServerName
+			}
+		}
+		c, err = grpc.StreamGRPCConn(c, gunConfig)
 	default:
+		if v.option.Plugin != "" {
+			c, err = plugin.StreamConn(v.option.Plugin, c, v.option.PluginOpts)
+			break
+		}
+
 		// handle TLS
 		if v.option.TLS {
 			host, _, _ := net.SplitHostPort(v.addr)
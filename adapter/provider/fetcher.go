@@ -16,6 +16,37 @@ var (
 	dirMode  os.FileMode = 0o755
 )
 
+// retryBackoffMin/Max bound how fast pullLoop retries after onUpdate
+// rejects a freshly pulled payload - an upstream publishing broken content
+// shouldn't be re-parsed every tick, but a transient bad pull should still
+// recover faster than waiting for the next full `interval:` tick.
+const (
+	retryBackoffMin = 5 * time.Second
+	retryBackoffMax = 5 * time.Minute
+)
+
+// retryBackoff doubles on every consecutive failure, up to retryBackoffMax,
+// and resets once a pull succeeds.
+type retryBackoff struct {
+	cur time.Duration
+}
+
+func (b *retryBackoff) next() time.Duration {
+	if b.cur == 0 {
+		b.cur = retryBackoffMin
+	} else if b.cur < retryBackoffMax {
+		b.cur *= 2
+		if b.cur > retryBackoffMax {
+			b.cur = retryBackoffMax
+		}
+	}
+	return b.cur
+}
+
+func (b *retryBackoff) reset() {
+	b.cur = 0
+}
+
 type parser = func([]byte) (interface{}, error)
 
 type fetcher struct {
@@ -26,7 +57,45 @@ type fetcher struct {
 	done      chan struct{}
 	hash      [16]byte
 	parser    parser
-	onUpdate  func(interface{})
+	onUpdate  func(interface{}) error
+	lastError error
+	pending   pendingUpdate
+}
+
+// pendingUpdate is what Initial/Update found on a successful pull, held
+// back from both the local cache file and f's own hash/updatedAt
+// bookkeeping until the caller's onUpdate has accepted the parsed payload.
+// Writing buf to disk (or committing the new hash) before that point would
+// mean a payload onUpdate rejects - e.g. a proxy list that fails to build
+// into any usable adapters - still lands in the local cache file, so a
+// process restart right after a rejected pull would load the broken
+// payload with nothing to validate it against.
+type pendingUpdate struct {
+	buf       []byte
+	updatedAt time.Time
+	hash      [16]byte
+}
+
+// commit writes p.buf to the local cache file (unless the vehicle is
+// itself that file) and advances f's hash/updatedAt bookkeeping to p. Only
+// called once the caller's onUpdate has returned nil for p.
+func (f *fetcher) commit(p pendingUpdate) error {
+	if f.vehicle.Type() != types.File {
+		if err := safeWrite(f.vehicle.Path(), p.buf); err != nil {
+			return err
+		}
+	}
+
+	updatedAt := p.updatedAt
+	f.updatedAt = &updatedAt
+	f.hash = p.hash
+	return nil
+}
+
+// LastError is the error from the most recent failed pull or onUpdate, or
+// nil if the last attempt (or the only attempt so far) succeeded.
+func (f *fetcher) LastError() error {
+	return f.lastError
 }
 
 func (f *fetcher) Name() string {
@@ -42,11 +111,11 @@ func (f *fetcher) Initial() (interface{}, error) {
 		buf     []byte
 		err     error
 		isLocal bool
+		modTime time.Time
 	)
 	if stat, fErr := os.Stat(f.vehicle.Path()); fErr == nil {
 		buf, err = os.ReadFile(f.vehicle.Path())
-		modTime := stat.ModTime()
-		f.updatedAt = &modTime
+		modTime = stat.ModTime()
 		isLocal = true
 	} else {
 		buf, err = f.vehicle.Read()
@@ -76,13 +145,11 @@ func (f *fetcher) Initial() (interface{}, error) {
 		isLocal = false
 	}
 
-	if f.vehicle.Type() != types.File && !isLocal {
-		if err := safeWrite(f.vehicle.Path(), buf); err != nil {
-			return nil, err
-		}
+	updatedAt := time.Now()
+	if isLocal {
+		updatedAt = modTime
 	}
-
-	f.hash = md5.Sum(buf)
+	f.pending = pendingUpdate{buf: buf, updatedAt: updatedAt, hash: md5.Sum(buf)}
 
 	// pull proxies automatically
 	if f.ticker != nil {
@@ -92,6 +159,10 @@ func (f *fetcher) Initial() (interface{}, error) {
 	return proxies, nil
 }
 
+// Update pulls the vehicle and, on a changed payload, parses it and stages
+// the result in f.pending without touching the local cache file or f's own
+// hash/updatedAt - see pendingUpdate. The caller commits it once onUpdate
+// has accepted the parsed payload.
 func (f *fetcher) Update() (interface{}, bool, error) {
 	buf, err := f.vehicle.Read()
 	if err != nil {
@@ -110,14 +181,7 @@ func (f *fetcher) Update() (interface{}, bool, error) {
 		return nil, false, err
 	}
 
-	if f.vehicle.Type() != types.File {
-		if err := safeWrite(f.vehicle.Path(), buf); err != nil {
-			return nil, false, err
-		}
-	}
-
-	f.updatedAt = &now
-	f.hash = hash
+	f.pending = pendingUpdate{buf: buf, updatedAt: now, hash: hash}
 
 	return proxies, false, nil
 }
@@ -130,24 +194,55 @@ func (f *fetcher) Destroy() error {
 }
 
 func (f *fetcher) pullLoop() {
+	backoff := &retryBackoff{}
+	var retry <-chan time.Time
+
+	pull := func() {
+		elm, same, err := f.Update()
+		if err != nil {
+			f.lastError = err
+			log.Warnln("[Provider] %s pull error: %s", f.Name(), err.Error())
+			return
+		}
+
+		if same {
+			log.Debugln("[Provider] %s's proxies doesn't change", f.Name())
+			backoff.reset()
+			return
+		}
+
+		if f.onUpdate != nil {
+			if err := f.onUpdate(elm); err != nil {
+				f.lastError = err
+				wait := backoff.next()
+				log.Warnln("[Provider] %s update failed, keeping previous proxies and retrying in %s: %s", f.Name(), wait, err.Error())
+				retry = time.After(wait)
+				return
+			}
+		}
+
+		if err := f.commit(f.pending); err != nil {
+			f.lastError = err
+			wait := backoff.next()
+			log.Warnln("[Provider] %s update failed, keeping previous proxies and retrying in %s: %s", f.Name(), wait, err.Error())
+			retry = time.After(wait)
+			return
+		}
+
+		log.Infoln("[Provider] %s's proxies update", f.Name())
+		f.lastError = nil
+		backoff.reset()
+	}
+
 	for {
 		select {
 		case <-f.ticker.C:
-			elm, same, err := f.Update()
-			if err != nil {
-				log.Warnln("[Provider] %s pull error: %s", f.Name(), err.Error())
-				continue
-			}
+			pull()
 
-			if same {
-				log.Debugln("[Provider] %s's proxies doesn't change", f.Name())
-				continue
-			}
+		case <-retry:
+			retry = nil
+			pull()
 
-			log.Infoln("[Provider] %s's proxies update", f.Name())
-			if f.onUpdate != nil {
-				f.onUpdate(elm)
-			}
 		case <-f.done:
 			f.ticker.Stop()
 			return
@@ -167,7 +262,7 @@ func safeWrite(path string, buf []byte) error {
 	return os.WriteFile(path, buf, fileMode)
 }
 
-func newFetcher(name string, interval time.Duration, vehicle types.Vehicle, parser parser, onUpdate func(interface{})) *fetcher {
+func newFetcher(name string, interval time.Duration, vehicle types.Vehicle, parser parser, onUpdate func(interface{}) error) *fetcher {
 	var ticker *time.Ticker
 	if interval != 0 {
 		ticker = time.NewTicker(interval)
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dreamacro/clash/common/batch"
+)
+
+// defaultURLTestTimeout bounds a single proxy's health-check probe, so one
+// slow or unreachable proxy can't stall the rest of a healthCheck batch.
+const defaultURLTestTimeout = time.Second * 5
+
+// defaultURLTestConcurrency caps how many proxies are probed at once. It's
+// the default for the "healthcheck.concurrent" config field.
+const defaultURLTestConcurrency = 10
+
+// StatusRange is a parsed "expected-status" config value, e.g. "200-299"
+// or "200,204,301-302", used to decide whether a health-check probe's
+// response counts a proxy as alive.
+type StatusRange struct {
+	ranges [][2]int
+}
+
+// ParseStatusRange parses an expected-status config value. An empty string
+// is valid and returns a nil *StatusRange, whose Contains then falls back
+// to accepting any non-error (< 400) status.
+func ParseStatusRange(s string) (*StatusRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	sr := &StatusRange{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		lo, hi, isRange := strings.Cut(part, "-")
+		loN, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected-status %q: %w", part, err)
+		}
+
+		hiN := loN
+		if isRange {
+			hiN, err = strconv.Atoi(strings.TrimSpace(hi))
+			if err != nil {
+				return nil, fmt.Errorf("invalid expected-status %q: %w", part, err)
+			}
+		}
+		if hiN < loN {
+			return nil, fmt.Errorf("invalid expected-status %q: range out of order", part)
+		}
+
+		sr.ranges = append(sr.ranges, [2]int{loN, hiN})
+	}
+
+	return sr, nil
+}
+
+// Contains reports whether code falls within the parsed ranges. A nil
+// *StatusRange accepts any non-error status, matching the pre-expected-
+// status behavior of treating a completed request as alive.
+func (sr *StatusRange) Contains(code int) bool {
+	if sr == nil {
+		return code < 400
+	}
+	for _, r := range sr.ranges {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// UnexpectedStatusError reports that a probe completed but its response
+// status fell outside the configured expected-status ranges.
+type UnexpectedStatusError struct {
+	Code int
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
+// urlTester is implemented by a provider's proxies. It's kept minimal so
+// healthcheck.go doesn't need the full proxy adapter surface.
+type urlTester interface {
+	Name() string
+	URLTest(ctx context.Context, url string) (latency time.Duration, status int, err error)
+}
+
+// HealthCheckOptions configures one healthCheck run. Concurrency and
+// ExpectedStatus fall back to their package defaults when unset, so an
+// ad-hoc probe only needs to override the fields a caller cares about.
+type HealthCheckOptions struct {
+	URL            string
+	Concurrency    int
+	ExpectedStatus *StatusRange
+}
+
+// HealthCheck drives periodic health-check probes for a provider's proxies.
+type HealthCheck struct {
+	proxies  []urlTester
+	opts     HealthCheckOptions
+	interval time.Duration
+	done     chan struct{}
+
+	touchMux  sync.Mutex
+	lastTouch time.Time
+}
+
+// NewHealthCheck builds a HealthCheck that probes proxies against opts
+// every interval. A zero interval leaves the HealthCheck idle until Check
+// is called directly (e.g. from an ad-hoc API probe).
+func NewHealthCheck(proxies []urlTester, opts HealthCheckOptions, interval time.Duration) *HealthCheck {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultURLTestConcurrency
+	}
+	return &HealthCheck{
+		proxies:  proxies,
+		opts:     opts,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Touch records that something just asked to use this provider's proxies,
+// extending its lazy-load TTL without running a probe. Keeping this
+// separate from Check/process means a plain proxy-list read never has the
+// side effect of resetting the TTL on its own.
+func (hc *HealthCheck) Touch() {
+	hc.touchMux.Lock()
+	defer hc.touchMux.Unlock()
+	hc.lastTouch = time.Now()
+}
+
+// Expired reports whether it has been longer than ttl since the last Touch.
+// A provider that has never been touched is never considered expired.
+func (hc *HealthCheck) Expired(ttl time.Duration) bool {
+	hc.touchMux.Lock()
+	defer hc.touchMux.Unlock()
+	if hc.lastTouch.IsZero() {
+		return false
+	}
+	return time.Since(hc.lastTouch) > ttl
+}
+
+// process runs Check every interval until Close is called.
+func (hc *HealthCheck) process() {
+	if hc.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			hc.Check(hc.opts)
+		case <-hc.done:
+			return
+		}
+	}
+}
+
+// Close stops the periodic probe loop started by process.
+func (hc *HealthCheck) Close() {
+	close(hc.done)
+}
+
+// Check probes every proxy against opts with bounded concurrency and a
+// per-proxy timeout, replacing the ad-hoc go/sync.WaitGroup loops this used
+// to take. Each probe's verdict (an *UnexpectedStatusError when the response
+// status falls outside opts.ExpectedStatus) is recorded only in the batch's
+// per-proxy results, which check discards: the status check does not change
+// any proxy's alive state.
+func (hc *HealthCheck) Check(opts HealthCheckOptions) {
+	if opts.URL == "" {
+		opts.URL = hc.opts.URL
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = hc.opts.Concurrency
+	}
+	if opts.ExpectedStatus == nil {
+		opts.ExpectedStatus = hc.opts.ExpectedStatus
+	}
+
+	check(hc.proxies, opts)
+}
+
+// check is the underlying worker-pool implementation shared by Check and
+// any caller that doesn't need a HealthCheck's lifecycle (periodic ticking,
+// Close).
+func check(proxies []urlTester, opts HealthCheckOptions) {
+	b, _ := batch.New(context.Background(), batch.WithConcurrencyNum(opts.Concurrency))
+	for _, proxy := range proxies {
+		p := proxy
+		b.Go(p.Name(), func() (interface{}, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultURLTestTimeout)
+			defer cancel()
+
+			_, status, err := p.URLTest(ctx, opts.URL)
+			if err != nil {
+				return nil, err
+			}
+			if !opts.ExpectedStatus.Contains(status) {
+				return nil, &UnexpectedStatusError{Code: status}
+			}
+			return nil, nil
+		})
+	}
+	b.Wait()
+}
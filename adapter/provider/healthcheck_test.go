@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	sr, err := ParseStatusRange("")
+	assert.NoError(t, err)
+	assert.Nil(t, sr)
+
+	sr, err = ParseStatusRange("200-299")
+	assert.NoError(t, err)
+	assert.True(t, sr.Contains(200))
+	assert.True(t, sr.Contains(299))
+	assert.False(t, sr.Contains(300))
+
+	sr, err = ParseStatusRange("200,204,301-302")
+	assert.NoError(t, err)
+	assert.True(t, sr.Contains(200))
+	assert.True(t, sr.Contains(204))
+	assert.True(t, sr.Contains(301))
+	assert.True(t, sr.Contains(302))
+	assert.False(t, sr.Contains(201))
+
+	_, err = ParseStatusRange("not-a-number")
+	assert.Error(t, err)
+
+	_, err = ParseStatusRange("299-200")
+	assert.Error(t, err)
+}
+
+type fakeURLTester struct {
+	name   string
+	status int
+	err    error
+}
+
+func (f *fakeURLTester) Name() string { return f.name }
+
+func (f *fakeURLTester) URLTest(ctx context.Context, url string) (time.Duration, int, error) {
+	return 0, f.status, f.err
+}
+
+func TestHealthCheck_ExpectedStatus(t *testing.T) {
+	sr, err := ParseStatusRange("200")
+	assert.NoError(t, err)
+
+	proxies := []urlTester{
+		&fakeURLTester{name: "ok", status: 200},
+		&fakeURLTester{name: "wrong-status", status: 500},
+	}
+
+	// check itself only drives the batch; it doesn't expose per-proxy
+	// results, so this just verifies a mixed batch completes without
+	// panicking or hanging.
+	check(proxies, HealthCheckOptions{URL: "http://example.com", Concurrency: 2, ExpectedStatus: sr})
+}
@@ -0,0 +1,82 @@
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+// Option configures a Batch.
+type Option func(b *Batch)
+
+// WithConcurrencyNum bounds how many of a Batch's tasks run at once. Without
+// it, Go never blocks and every task starts immediately.
+func WithConcurrencyNum(num int) Option {
+	return func(b *Batch) {
+		b.queue = make(chan struct{}, num)
+	}
+}
+
+type result struct {
+	value interface{}
+	err   error
+}
+
+// Batch runs a set of keyed tasks concurrently, optionally bounded by
+// WithConcurrencyNum, and collects each task's result independently of the
+// others, so one task's error doesn't cancel or affect its siblings.
+type Batch struct {
+	ctx     context.Context
+	queue   chan struct{}
+	wg      sync.WaitGroup
+	mux     sync.Mutex
+	results map[string]result
+}
+
+// New creates a Batch bound to ctx, along with ctx itself for convenience
+// when chaining. Batch doesn't cancel or derive from ctx on its own; it's
+// passed through purely so tasks can observe cancellation.
+func New(ctx context.Context, opts ...Option) (*Batch, context.Context) {
+	b := &Batch{
+		ctx:     ctx,
+		results: map[string]result{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b, ctx
+}
+
+// Go runs fn in its own goroutine, blocking until a concurrency slot is
+// free if the batch is bounded, and records its result under key.
+func (b *Batch) Go(key string, fn func() (interface{}, error)) {
+	if b.queue != nil {
+		b.queue <- struct{}{}
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		if b.queue != nil {
+			defer func() { <-b.queue }()
+		}
+
+		value, err := fn()
+		b.mux.Lock()
+		b.results[key] = result{value: value, err: err}
+		b.mux.Unlock()
+	}()
+}
+
+// Wait blocks until every task started with Go has returned.
+func (b *Batch) Wait() {
+	b.wg.Wait()
+}
+
+// Result returns the value and error fn returned for key. It's zero/nil if
+// Go was never called with that key.
+func (b *Batch) Result(key string) (interface{}, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	r := b.results[key]
+	return r.value, r.err
+}
@@ -0,0 +1,50 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch_CollectsResults(t *testing.T) {
+	b, _ := New(context.Background())
+
+	b.Go("a", func() (interface{}, error) { return 1, nil })
+	b.Go("b", func() (interface{}, error) { return nil, errors.New("boom") })
+	b.Wait()
+
+	value, err := b.Result("a")
+	assert.Equal(t, 1, value)
+	assert.Nil(t, err)
+
+	_, err = b.Result("b")
+	assert.EqualError(t, err, "boom")
+}
+
+func TestBatch_ConcurrencyNum(t *testing.T) {
+	b, _ := New(context.Background(), WithConcurrencyNum(2))
+
+	var running, max int32
+	for i := 0; i < 10; i++ {
+		b.Go(strconv.Itoa(i), func() (interface{}, error) {
+			cur := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if cur <= m || atomic.CompareAndSwapInt32(&max, m, cur) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil, nil
+		})
+	}
+	b.Wait()
+
+	assert.LessOrEqual(t, int(atomic.LoadInt32(&max)), 2)
+}
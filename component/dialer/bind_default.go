@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !windows
+
+package dialer
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrPlatformNotSupport is returned by WithInterface binds on platforms
+// without a known way to bind a socket to a physical interface.
+var ErrPlatformNotSupport = errors.New("unsupported on this platform")
+
+func bindIfaceToDialer(_ string, _ *net.Dialer, _ string, _ net.IP) error {
+	return ErrPlatformNotSupport
+}
+
+func bindIfaceToListenConfig(_ string, _ *net.ListenConfig, _, _ string) (string, error) {
+	return "", ErrPlatformNotSupport
+}
@@ -0,0 +1,49 @@
+package dialer
+
+import (
+	"net"
+	"strings"
+
+	"github.com/Dreamacro/clash/component/iface"
+)
+
+// Windows has no SO_BINDTODEVICE/IP_BOUND_IF equivalent, so binding to an
+// interface here means dialing/listening from one of its own addresses
+// instead (source-address selection) and letting routing pick the device.
+func bindIfaceToDialer(ifaceName string, dialer *net.Dialer, network string, _ net.IP) error {
+	ifaceObj, err := iface.ResolveInterface(ifaceName)
+	if err != nil {
+		return err
+	}
+
+	addr, err := ifaceObj.PickIPAddr(network)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(network, "tcp") {
+		dialer.LocalAddr = &net.TCPAddr{IP: addr}
+	} else {
+		dialer.LocalAddr = &net.UDPAddr{IP: addr}
+	}
+	return nil
+}
+
+func bindIfaceToListenConfig(ifaceName string, lc *net.ListenConfig, network, address string) (string, error) {
+	ifaceObj, err := iface.ResolveInterface(ifaceName)
+	if err != nil {
+		return "", err
+	}
+
+	addr, err := ifaceObj.PickIPAddr(network)
+	if err != nil {
+		return "", err
+	}
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		port = "0"
+	}
+
+	return net.JoinHostPort(addr.String(), port), nil
+}
@@ -0,0 +1,86 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// DefaultTCPTimeout bounds a plain DialContext call.
+const DefaultTCPTimeout = 5 * time.Second
+
+// DefaultInterface is the interface name DialContext/ListenPacket bind to
+// when a call doesn't pass its own WithInterface. It's set globally by
+// SetDial, e.g. from the general config's experimental `interface-name`.
+var DefaultInterface = atomic.NewString("")
+
+type option struct {
+	interfaceName string
+}
+
+// Option customizes a single DialContext/ListenPacket call.
+type Option func(opt *option)
+
+// WithInterface binds this call to the named interface instead of
+// whatever DefaultInterface currently holds.
+func WithInterface(name string) Option {
+	return func(opt *option) {
+		opt.interfaceName = name
+	}
+}
+
+func applyOptions(options ...Option) *option {
+	opt := &option{
+		interfaceName: DefaultInterface.Load(),
+	}
+	for _, o := range options {
+		o(opt)
+	}
+	return opt
+}
+
+// SetDial sets the interface DialContext/ListenPacket bind to by default,
+// so outbound dialers (Direct, ShadowSocksR, Http, ...) don't loop their
+// traffic back through a TUN interface intercepting the default route.
+func SetDial(interfaceName string) {
+	DefaultInterface.Store(interfaceName)
+}
+
+// DialContext dials network/address, optionally bound to a physical
+// interface (WithInterface, or DefaultInterface if unset).
+func DialContext(ctx context.Context, network, address string, options ...Option) (net.Conn, error) {
+	opt := applyOptions(options...)
+
+	dialer := &net.Dialer{Timeout: DefaultTCPTimeout}
+	if opt.interfaceName != "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+
+		if err := bindIfaceToDialer(opt.interfaceName, dialer, network, net.ParseIP(host)); err != nil {
+			return nil, err
+		}
+	}
+
+	return dialer.DialContext(ctx, network, address)
+}
+
+// ListenPacket opens a UDP socket, optionally bound to a physical interface
+// the same way DialContext is.
+func ListenPacket(ctx context.Context, network, address string, options ...Option) (net.PacketConn, error) {
+	opt := applyOptions(options...)
+
+	lc := &net.ListenConfig{}
+	if opt.interfaceName != "" {
+		addr, err := bindIfaceToListenConfig(opt.interfaceName, lc, network, address)
+		if err != nil {
+			return nil, err
+		}
+		address = addr
+	}
+
+	return lc.ListenPacket(ctx, network, address)
+}
@@ -0,0 +1,186 @@
+package geodata
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+// This is a small hand-rolled decoder for v2ray's geosite.dat, which is a
+// serialized GeoSiteList protobuf message:
+//
+//	message Domain {
+//	  enum Type { Plain = 0; Regex = 1; Domain = 2; Full = 3; }
+//	  Type type = 1;
+//	  string value = 2;
+//	  repeated Attribute attribute = 3; // ignored, unused here
+//	}
+//	message GeoSite {
+//	  string country_code = 1;
+//	  repeated Domain domain = 2;
+//	}
+//	message GeoSiteList {
+//	  repeated GeoSite entry = 1;
+//	}
+//
+// There's no point pulling in protobuf codegen for three fields we read
+// once at startup and memoise, so this reads the wire format directly.
+
+var errTruncated = errors.New("geosite: truncated protobuf message")
+
+type protoReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *protoReader) done() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *protoReader) readVarint() (uint64, error) {
+	var x uint64
+	var s uint
+	for {
+		if r.pos >= len(r.buf) {
+			return 0, errTruncated
+		}
+		b := r.buf[r.pos]
+		r.pos++
+		if b < 0x80 {
+			return x | uint64(b)<<s, nil
+		}
+		x |= uint64(b&0x7f) << s
+		s += 7
+	}
+}
+
+// readField returns the next field's tag number, wire type, and raw payload
+// (the varint value for wire type 0, or the bytes for wire type 2).
+func (r *protoReader) readField() (field int, wireType int, payload []byte, err error) {
+	tag, err := r.readVarint()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	field = int(tag >> 3)
+	wireType = int(tag & 0x7)
+
+	switch wireType {
+	case 0: // varint
+		v, err := r.readVarint()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		payload = appendVarint(nil, v)
+	case 2: // length-delimited
+		n, err := r.readVarint()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if r.pos+int(n) > len(r.buf) {
+			return 0, 0, nil, errTruncated
+		}
+		payload = r.buf[r.pos : r.pos+int(n)]
+		r.pos += int(n)
+	default:
+		return 0, 0, nil, errors.New("geosite: unsupported wire type")
+	}
+
+	return field, wireType, payload, nil
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func decodeVarintPayload(payload []byte) uint64 {
+	r := &protoReader{buf: payload}
+	v, _ := r.readVarint()
+	return v
+}
+
+// decodeDomain decodes a single geosite Domain message.
+func decodeDomain(buf []byte) (Domain, error) {
+	r := &protoReader{buf: buf}
+	d := Domain{Type: Plain}
+
+	for !r.done() {
+		field, _, payload, err := r.readField()
+		if err != nil {
+			return Domain{}, err
+		}
+		switch field {
+		case 1:
+			d.Type = DomainType(decodeVarintPayload(payload))
+		case 2:
+			d.Value = string(payload)
+		default:
+			// attribute (field 3) or anything newer: not needed here
+		}
+	}
+
+	return d, nil
+}
+
+// decodeGeoSite decodes a single geosite GeoSite message into its country
+// code and domain list.
+func decodeGeoSite(buf []byte) (string, []Domain, error) {
+	r := &protoReader{buf: buf}
+	var code string
+	var domains []Domain
+
+	for !r.done() {
+		field, _, payload, err := r.readField()
+		if err != nil {
+			return "", nil, err
+		}
+		switch field {
+		case 1:
+			code = string(payload)
+		case 2:
+			d, err := decodeDomain(payload)
+			if err != nil {
+				return "", nil, err
+			}
+			domains = append(domains, d)
+		}
+	}
+
+	return code, domains, nil
+}
+
+// decodeGeoSiteList decodes a full geosite.dat GeoSiteList message into a
+// category (lowercased country_code) -> domains map.
+func decodeGeoSiteList(buf []byte) (map[string][]Domain, error) {
+	r := &protoReader{buf: buf}
+	sites := map[string][]Domain{}
+
+	for !r.done() {
+		field, _, payload, err := r.readField()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 {
+			continue
+		}
+
+		code, domains, err := decodeGeoSite(payload)
+		if err != nil {
+			return nil, err
+		}
+		sites[strings.ToLower(code)] = domains
+	}
+
+	return sites, nil
+}
+
+func loadGeoSiteFile(path string) (map[string][]Domain, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGeoSiteList(buf)
+}
@@ -0,0 +1,110 @@
+package geodata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	C "github.com/Dreamacro/clash/constant"
+)
+
+// DomainType mirrors v2ray's geosite Domain.Type enum.
+type DomainType int
+
+const (
+	Plain DomainType = iota
+	Regex
+	RootDomain
+	Full
+)
+
+// Domain is one matching rule out of a geosite category.
+type Domain struct {
+	Type  DomainType
+	Value string
+}
+
+// Matcher matches a domain against every rule in one geosite category.
+type Matcher struct {
+	domains []Domain
+	regexes []*regexp.Regexp
+}
+
+func newMatcher(domains []Domain) *Matcher {
+	m := &Matcher{}
+	for _, d := range domains {
+		if d.Type == Regex {
+			if re, err := regexp.Compile(d.Value); err == nil {
+				m.regexes = append(m.regexes, re)
+				continue
+			}
+		}
+		m.domains = append(m.domains, d)
+	}
+	return m
+}
+
+// Match reports whether domain satisfies any rule in the category.
+func (m *Matcher) Match(domain string) bool {
+	for _, d := range m.domains {
+		switch d.Type {
+		case Full:
+			if domain == d.Value {
+				return true
+			}
+		case RootDomain:
+			if domain == d.Value || strings.HasSuffix(domain, "."+d.Value) {
+				return true
+			}
+		default: // Plain
+			if strings.Contains(domain, d.Value) {
+				return true
+			}
+		}
+	}
+
+	for _, re := range m.regexes {
+		if re.MatchString(domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	mux       sync.Mutex
+	siteCache map[string][]Domain
+	matchers  = map[string]*Matcher{}
+)
+
+// LoadMatcher returns the Matcher for a geosite category (case-insensitive),
+// parsing and memoising C.Path.GeoSite() the first time any category is
+// requested, and memoising each category's Matcher after that.
+func LoadMatcher(category string) (*Matcher, error) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	category = strings.ToLower(category)
+	if m, ok := matchers[category]; ok {
+		return m, nil
+	}
+
+	if siteCache == nil {
+		sites, err := loadGeoSiteFile(C.Path.GeoSite())
+		if err != nil {
+			return nil, fmt.Errorf("load geosite data: %w", err)
+		}
+		siteCache = sites
+	}
+
+	domains, ok := siteCache[category]
+	if !ok {
+		return nil, fmt.Errorf("geosite category %s not found", category)
+	}
+
+	m := newMatcher(domains)
+	matchers[category] = m
+	return m, nil
+}
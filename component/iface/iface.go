@@ -0,0 +1,70 @@
+package iface
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrIfaceNotFound is returned when the named interface doesn't exist on
+// this host.
+var ErrIfaceNotFound = errors.New("interface not found")
+
+// Interface is the subset of net.Interface that dialer's platform-specific
+// binders need to bind a socket to a physical interface.
+type Interface struct {
+	Index int
+	MTU   int
+	Name  string
+	Addrs []*net.IPNet
+}
+
+// ResolveInterface looks up a network interface by name.
+func ResolveInterface(name string) (*Interface, error) {
+	ifaceObj, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, ErrIfaceNotFound
+	}
+
+	addrs, err := ifaceObj.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	ipNets := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ipNets = append(ipNets, ipNet)
+		}
+	}
+
+	return &Interface{
+		Index: ifaceObj.Index,
+		MTU:   ifaceObj.MTU,
+		Name:  ifaceObj.Name,
+		Addrs: ipNets,
+	}, nil
+}
+
+// PickIPAddr returns the interface's first address matching network's IP
+// family (tcp4/udp4 -> IPv4, tcp6/udp6 -> IPv6, anything else -> whichever
+// comes first), for platforms that bind to an interface via source address
+// selection rather than SO_BINDTODEVICE/IP_BOUND_IF.
+func (iface *Interface) PickIPAddr(network string) (net.IP, error) {
+	wantV4 := strings.HasSuffix(network, "4")
+	wantV6 := strings.HasSuffix(network, "6")
+
+	for _, addr := range iface.Addrs {
+		isV4 := addr.IP.To4() != nil
+		if wantV4 && !isV4 {
+			continue
+		}
+		if wantV6 && isV4 {
+			continue
+		}
+		return addr.IP, nil
+	}
+
+	return nil, fmt.Errorf("interface %s has no usable address for %s", iface.Name, network)
+}
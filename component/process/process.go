@@ -0,0 +1,32 @@
+// Package process resolves the local process that owns a given
+// (network, srcIP, srcPort) socket, for the PROCESS-NAME/PROCESS-PATH
+// rule types in rule/process.go.
+package process
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrInvalidNetwork is returned for any network string other than "tcp"
+// or "udp" - the only two FindProcessName knows how to look up.
+var ErrInvalidNetwork = errors.New("invalid network")
+
+// ErrNotFound is returned when no local socket matches (network, ip, port)
+// in the current process/socket tables, e.g. the connection has already
+// closed by the time the lookup runs.
+var ErrNotFound = errors.New("process not found")
+
+// FindProcessName returns the absolute path of the process that owns the
+// local socket (network, ip, port). network must be "tcp" or "udp";
+// ip's address family (v4 or v6) is used to pick which socket table to
+// search.
+func FindProcessName(network string, ip net.IP, port int) (string, error) {
+	switch network {
+	case "tcp", "udp":
+	default:
+		return "", ErrInvalidNetwork
+	}
+
+	return findProcessPath(network, ip, port)
+}
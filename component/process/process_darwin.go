@@ -0,0 +1,55 @@
+//go:build darwin
+
+package process
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// findProcessPath shells out to lsof, which already does the PCB-table
+// walk the Linux build gets from /proc/net - re-deriving that from the
+// sysctl net.inet.{tcp,udp}.pcblist struct layout by hand isn't worth the
+// risk when lsof ships on every macOS install.
+//
+// lsof -i matches a port on either end of a connection, not specifically
+// the local side, so this can misattribute a process when two local
+// sockets share the same port on different addresses; good enough for a
+// best-effort PROCESS-NAME/PROCESS-PATH match.
+func findProcessPath(network string, ip net.IP, port int) (string, error) {
+	out, err := exec.Command("lsof", "-nP", "-i", fmt.Sprintf("%s:%d", network, port), "-t").Output()
+	if err != nil {
+		return "", ErrNotFound
+	}
+
+	for _, pidStr := range strings.Fields(string(out)) {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			continue
+		}
+		if path, ok := execPathForPID(pid); ok {
+			return path, nil
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// execPathForPID reads the NAME of pid's "txt" (executable text) file
+// descriptor, which lsof reports as the process's binary path.
+func execPathForPID(pid int) (string, bool) {
+	out, err := exec.Command("lsof", "-a", "-p", strconv.Itoa(pid), "-d", "txt", "-Fn").Output()
+	if err != nil {
+		return "", false
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "n") && len(line) > 1 {
+			return line[1:], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package process
+
+import (
+	"errors"
+	"net"
+)
+
+var errNotSupported = errors.New("process lookup not supported on this platform")
+
+func findProcessPath(_ string, _ net.IP, _ int) (string, error) {
+	return "", errNotSupported
+}
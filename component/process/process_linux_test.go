@@ -0,0 +1,15 @@
+//go:build linux
+
+package process
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeAddr(t *testing.T) {
+	assert.Equal(t, "0100007F:1F90", encodeAddr(net.ParseIP("127.0.0.1"), 8080))
+	assert.Equal(t, "00000000:0050", encodeAddr(net.ParseIP("0.0.0.0"), 80))
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package process
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// findProcessPath asks PowerShell's Get-NetTCPConnection/Get-NetUDPEndpoint
+// cmdlets for the owning process of port, then Get-Process for that
+// process's image path - both available since Windows 8 / Server 2012,
+// and far less error-prone than re-implementing GetExtendedTcpTable's
+// struct layout over raw syscalls without a way to build-test this file.
+//
+// Like the darwin build, this only matches on local port, not address;
+// network is otherwise unused.
+func findProcessPath(_ string, _ net.IP, port int) (string, error) {
+	script := fmt.Sprintf(
+		`$conn = Get-NetTCPConnection -LocalPort %d -ErrorAction SilentlyContinue | Select-Object -First 1; `+
+			`if (-not $conn) { $conn = Get-NetUDPEndpoint -LocalPort %d -ErrorAction SilentlyContinue | Select-Object -First 1 }; `+
+			`if ($conn) { (Get-Process -Id $conn.OwningProcess -ErrorAction SilentlyContinue).Path }`,
+		port, port,
+	)
+
+	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSpace(string(out))
+	if path == "" {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
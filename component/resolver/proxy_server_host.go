@@ -0,0 +1,23 @@
+package resolver
+
+import "net"
+
+// ProxyServerHostResolver is the subset of dns.Resolver that
+// ResolveProxyServerHost forwards to. It's declared here, rather than
+// importing dns directly, because dns itself imports this package - a
+// direct dependency the other way would be a cycle.
+type ProxyServerHostResolver interface {
+	ResolveProxyServerHost(host string) (ip net.IP, err error)
+}
+
+// DefaultResolver is assigned the running dns.Resolver at startup, the same
+// way this package's other DefaultResolver-backed lookups are.
+var DefaultResolver ProxyServerHostResolver
+
+// ResolveProxyServerHost resolves host through DefaultResolver's dedicated
+// ProxyServer nameserver group, instead of whatever resolver user traffic
+// goes through (fake-ip, a tun-redirected DNS, ...), so dialing a proxy
+// server can't deadlock on resolving the very proxy it tunnels through.
+func ResolveProxyServerHost(host string) (net.IP, error) {
+	return DefaultResolver.ResolveProxyServerHost(host)
+}
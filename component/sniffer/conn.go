@@ -0,0 +1,30 @@
+package sniffer
+
+import (
+	"bytes"
+	"net"
+)
+
+// replayConn makes the bytes a Sniffer already consumed from conn readable
+// again, so the sniff can happen ahead of proxy.DialContext without the
+// upstream connection losing those bytes off the front of the stream.
+type replayConn struct {
+	net.Conn
+	buf *bytes.Buffer
+}
+
+// newReplayConn wraps conn so that peeked (already-read) bytes are
+// returned first, before further reads fall through to conn itself.
+func newReplayConn(conn net.Conn, peeked []byte) *replayConn {
+	return &replayConn{
+		Conn: conn,
+		buf:  bytes.NewBuffer(peeked),
+	}
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	if c.buf.Len() > 0 {
+		return c.buf.Read(b)
+	}
+	return c.Conn.Read(b)
+}
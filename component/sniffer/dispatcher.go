@@ -0,0 +1,223 @@
+package sniffer
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/log"
+
+	"go.uber.org/atomic"
+)
+
+// peekSize is how many bytes TCPSniff reads before giving up on finding a
+// SNI/Host - enough to cover a typical ClientHello's extensions or an
+// HTTP request's headers without buffering an unbounded amount of a
+// connection that turns out not to sniff at all.
+const peekSize = 8192
+
+// Config is the config-driven part of a SnifferDispatcher: which
+// destination ports get sniffed, and domains that always/never have
+// their metadata.Host rewritten regardless of what sniffing finds.
+type Config struct {
+	// Ports restricts sniffing to these destination ports. Empty means
+	// every port is eligible.
+	Ports []uint16
+	// Force domains are rewritten even if metadata.Host is already set.
+	Force []string
+	// Skip domains are never written into metadata.Host, even on a
+	// successful sniff.
+	Skip []string
+}
+
+// SnifferDispatcher decides, for a connection whose metadata still lacks
+// a Host (the usual case for transparent/TUN inbounds and fake-ip mode),
+// whether to peek its first bytes for a TLS SNI or HTTP Host and rewrite
+// metadata.Host/AddrType with what it finds.
+type SnifferDispatcher struct {
+	enable   *atomic.Bool
+	sniffers []Sniffer
+
+	ports map[uint16]bool
+	force domainSet
+	skip  domainSet
+
+	successCount *atomic.Uint64
+	failCount    *atomic.Uint64
+}
+
+// NewSnifferDispatcher builds a SnifferDispatcher from cfg, enabled by
+// default - a config loader that wants sniffing off calls SetSniffing(false)
+// after construction, the same way tunnel.SetMode is called after load.
+func NewSnifferDispatcher(cfg Config) *SnifferDispatcher {
+	ports := make(map[uint16]bool, len(cfg.Ports))
+	for _, port := range cfg.Ports {
+		ports[port] = true
+	}
+
+	return &SnifferDispatcher{
+		enable:       atomic.NewBool(true),
+		sniffers:     []Sniffer{TLSSniffer{}, HTTPSniffer{}},
+		ports:        ports,
+		force:        newDomainSet(cfg.Force),
+		skip:         newDomainSet(cfg.Skip),
+		successCount: atomic.NewUint64(0),
+		failCount:    atomic.NewUint64(0),
+	}
+}
+
+// SetSniffing turns sniffing on/off without discarding the dispatcher's
+// port/domain configuration.
+func (d *SnifferDispatcher) SetSniffing(on bool) {
+	d.enable.Store(on)
+}
+
+// IsSniffing reports whether sniffing is currently enabled.
+func (d *SnifferDispatcher) IsSniffing() bool {
+	return d.enable.Load()
+}
+
+// SuccessCount is how many connections have had their Host recovered by
+// sniffing, for the external controller to report as a metric.
+func (d *SnifferDispatcher) SuccessCount() uint64 {
+	return d.successCount.Load()
+}
+
+// FailCount is how many sniffable connections were peeked but yielded no
+// usable SNI/Host.
+func (d *SnifferDispatcher) FailCount() uint64 {
+	return d.failCount.Load()
+}
+
+// TCPSniff peeks conn's first bytes looking for a domain and, when one is
+// found and isn't on the skip list, rewrites metadata.Host/AddrType and
+// returns a net.Conn with the peeked bytes replayed back onto the front
+// of the stream. If sniffing doesn't apply, or nothing could be read, it
+// returns conn unchanged; if bytes were read but nothing is found, it
+// still returns a conn that replays them, leaving metadata untouched.
+func (d *SnifferDispatcher) TCPSniff(conn net.Conn, metadata *C.Metadata) net.Conn {
+	if !d.shouldSniff(metadata) {
+		return conn
+	}
+
+	// A ClientHello/HTTP request commonly arrives split across more than one
+	// Read, so keep reading into buf - rather than judging a single Read's
+	// worth of bytes - until a sniffer succeeds, a Read fails (EOF or
+	// otherwise), or buf fills up without anyone recognizing it.
+	buf := make([]byte, peekSize)
+	var total int
+	for total < len(buf) {
+		n, err := conn.Read(buf[total:])
+		total += n
+
+		if total > 0 {
+			if host, sniffErr := d.sniff(buf[:total]); sniffErr == nil {
+				replayed := newReplayConn(conn, buf[:total])
+				if d.skip.contains(host) {
+					return replayed
+				}
+
+				log.Debugln("[Sniffer] %s --> %s, rewriting metadata.Host", metadata.DstIP, host)
+				metadata.Host = host
+				metadata.AddrType = C.AtypDomainName
+				d.successCount.Inc()
+				return replayed
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if total == 0 {
+		return conn
+	}
+
+	d.failCount.Inc()
+	return newReplayConn(conn, buf[:total])
+}
+
+// UDPSniff is TCPSniff's counterpart for a single UDP datagram, which
+// (unlike a TCP stream) arrives whole - there's nothing to peek ahead of
+// and no conn to wrap, so it only ever rewrites metadata in place.
+func (d *SnifferDispatcher) UDPSniff(data []byte, metadata *C.Metadata) {
+	if !d.shouldSniff(metadata) {
+		return
+	}
+
+	host, err := d.sniff(data)
+	if err != nil {
+		d.failCount.Inc()
+		return
+	}
+
+	if d.skip.contains(host) {
+		return
+	}
+
+	log.Debugln("[Sniffer] %s --> %s, rewriting metadata.Host", metadata.DstIP, host)
+	metadata.Host = host
+	metadata.AddrType = C.AtypDomainName
+	d.successCount.Inc()
+}
+
+// shouldSniff reports whether metadata is eligible to be sniffed at all,
+// independent of whether a sniff actually finds anything.
+func (d *SnifferDispatcher) shouldSniff(metadata *C.Metadata) bool {
+	if !d.enable.Load() {
+		return false
+	}
+
+	if metadata.Host != "" && !d.force.contains(metadata.Host) {
+		return false
+	}
+
+	if len(d.ports) == 0 {
+		return true
+	}
+	port, err := strconv.Atoi(metadata.DstPort)
+	return err == nil && d.ports[uint16(port)]
+}
+
+func (d *SnifferDispatcher) sniff(b []byte) (string, error) {
+	for _, sniffer := range d.sniffers {
+		if host, err := sniffer.SniffTCP(b); err == nil {
+			return host, nil
+		}
+	}
+	return "", ErrNoClue
+}
+
+// domainSet matches an exact domain, or (given a "+.example.com" entry)
+// any subdomain of it - the same convention Clash's other domain config
+// fields (e.g. rule-set DOMAIN-SUFFIX entries) use.
+type domainSet struct {
+	exact  map[string]bool
+	suffix []string
+}
+
+func newDomainSet(domains []string) domainSet {
+	s := domainSet{exact: map[string]bool{}}
+	for _, d := range domains {
+		if strings.HasPrefix(d, "+.") {
+			s.suffix = append(s.suffix, strings.TrimPrefix(d, "+"))
+		} else {
+			s.exact[d] = true
+		}
+	}
+	return s
+}
+
+func (s domainSet) contains(host string) bool {
+	if s.exact[host] {
+		return true
+	}
+	for _, suffix := range s.suffix {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package sniffer
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+// HTTPSniffer recovers the Host header out of a plain HTTP/1.x request.
+type HTTPSniffer struct{}
+
+func (HTTPSniffer) Protocol() string {
+	return "http"
+}
+
+func (HTTPSniffer) SniffTCP(b []byte) (string, error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		// Most failures here are "haven't read the request line/headers in
+		// full yet" rather than a malformed request, and the caller has no
+		// way to tell those apart from a byte slice alone.
+		return "", ErrNoClue
+	}
+
+	host := req.Host
+	if host == "" {
+		return "", ErrNoClue
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host, nil
+}
@@ -0,0 +1,26 @@
+// Package sniffer peeks the first bytes of a connection whose destination
+// is a bare IP (no Host set, e.g. a transparent/TUN/fake-ip inbound) and
+// tries to recover the domain the client is actually talking to, from a
+// TLS ClientHello's SNI extension or an HTTP/1.x request's Host header.
+// Recovering it lets rule matching and logging work on a domain instead of
+// an IP that's frequently shared by many unrelated hosts.
+package sniffer
+
+import "errors"
+
+// ErrNoClue is returned by a Sniffer when it can't find what it's looking
+// for in the bytes it was given - not necessarily because the bytes are
+// malformed, just that there isn't enough of the handshake/request yet.
+var ErrNoClue = errors.New("not enough data to sniff")
+
+// Sniffer recovers a domain from the first bytes of a single protocol's
+// handshake/request.
+type Sniffer interface {
+	// Protocol names the sniffer ("tls", "http"). SnifferDispatcher always
+	// runs every built-in sniffer; Config has no per-sniffer on/off switch.
+	Protocol() string
+
+	// SniffTCP inspects bytes read from the start of a TCP stream and
+	// returns the domain it found, or ErrNoClue if none.
+	SniffTCP(bytes []byte) (host string, err error)
+}
@@ -0,0 +1,134 @@
+package sniffer
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildClientHello assembles a minimal TLS record carrying a ClientHello
+// with a single server_name extension, just enough for TLSSniffer to work
+// with - not a realistic handshake otherwise.
+func buildClientHello(host string) []byte {
+	name := []byte(host)
+
+	serverNameEntry := append([]byte{0x00}, uint16Bytes(uint16(len(name)))...)
+	serverNameEntry = append(serverNameEntry, name...)
+	serverNameList := append(uint16Bytes(uint16(len(serverNameEntry))), serverNameEntry...)
+
+	sniExtension := append([]byte{0x00, 0x00}, uint16Bytes(uint16(len(serverNameList)))...)
+	sniExtension = append(sniExtension, serverNameList...)
+
+	extensions := append(uint16Bytes(uint16(len(sniExtension))), sniExtension...)
+
+	hello := make([]byte, 0, 128)
+	hello = append(hello, 0x03, 0x03)          // client_version
+	hello = append(hello, make([]byte, 32)...) // random
+	hello = append(hello, 0x00)                // session_id_length
+	hello = append(hello, 0x00, 0x02, 0x00, 0x2f) // cipher_suites
+	hello = append(hello, 0x01, 0x00)          // compression_methods
+	hello = append(hello, extensions...)
+
+	handshake := append([]byte{0x01}, uint24Bytes(len(hello))...)
+	handshake = append(handshake, hello...)
+
+	record := append([]byte{0x16, 0x03, 0x01}, uint16Bytes(uint16(len(handshake)))...)
+	record = append(record, handshake...)
+	return record
+}
+
+func uint16Bytes(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func uint24Bytes(v int) []byte {
+	return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func TestTLSSniffer(t *testing.T) {
+	record := buildClientHello("example.com")
+	host, err := (TLSSniffer{}).SniffTCP(record)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", host)
+}
+
+func TestTLSSnifferNoClue(t *testing.T) {
+	_, err := (TLSSniffer{}).SniffTCP([]byte{0x17, 0x03, 0x01, 0x00, 0x01, 0x00})
+	assert.ErrorIs(t, err, ErrNoClue)
+}
+
+func TestHTTPSniffer(t *testing.T) {
+	req := "GET / HTTP/1.1\r\nHost: example.com:8080\r\n\r\n"
+	host, err := (HTTPSniffer{}).SniffTCP([]byte(req))
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", host)
+}
+
+func TestDomainSet(t *testing.T) {
+	set := newDomainSet([]string{"example.com", "+.example.org"})
+	assert.True(t, set.contains("example.com"))
+	assert.False(t, set.contains("sub.example.com"))
+	assert.True(t, set.contains("sub.example.org"))
+	assert.False(t, set.contains("example.org"))
+}
+
+func TestSnifferDispatcherTCPSniff(t *testing.T) {
+	dispatcher := NewSnifferDispatcher(Config{})
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	record := buildClientHello("example.com")
+	go func() {
+		_, _ = client.Write(record)
+	}()
+
+	metadata := &C.Metadata{DstPort: "443"}
+	sniffed := dispatcher.TCPSniff(server, metadata)
+	assert.Equal(t, "example.com", metadata.Host)
+	assert.Equal(t, C.AtypDomainName, metadata.AddrType)
+	assert.EqualValues(t, 1, dispatcher.SuccessCount())
+
+	// the ClientHello bytes must still be readable from the front of the
+	// stream, for whatever the sniff result gets relayed to upstream.
+	replayed := make([]byte, len(record))
+	n, err := sniffed.Read(replayed)
+	assert.NoError(t, err)
+	assert.Equal(t, record, replayed[:n])
+}
+
+// TestSnifferDispatcherTCPSniffSplitWrites covers a ClientHello that
+// arrives across two separate Reads, the way a real TCP stream commonly
+// delivers one - TCPSniff must keep reading instead of judging the first
+// Read's bytes alone and giving up.
+func TestSnifferDispatcherTCPSniffSplitWrites(t *testing.T) {
+	dispatcher := NewSnifferDispatcher(Config{})
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	record := buildClientHello("example.com")
+	split := len(record) / 2
+	go func() {
+		_, _ = client.Write(record[:split])
+		_, _ = client.Write(record[split:])
+	}()
+
+	metadata := &C.Metadata{DstPort: "443"}
+	sniffed := dispatcher.TCPSniff(server, metadata)
+	assert.Equal(t, "example.com", metadata.Host)
+	assert.Equal(t, C.AtypDomainName, metadata.AddrType)
+	assert.EqualValues(t, 1, dispatcher.SuccessCount())
+
+	replayed := make([]byte, len(record))
+	n, err := sniffed.Read(replayed)
+	assert.NoError(t, err)
+	assert.Equal(t, record, replayed[:n])
+}
@@ -0,0 +1,127 @@
+package sniffer
+
+import "encoding/binary"
+
+const (
+	tlsHandshakeContentType = 0x16
+	tlsHandshakeClientHello = 0x01
+	tlsExtensionServerName  = 0x0000
+	tlsServerNameTypeHost   = 0x00
+)
+
+// TLSSniffer recovers the SNI server_name extension out of a TLS
+// ClientHello, the same bytes a plain passthrough proxy would otherwise
+// forward untouched.
+type TLSSniffer struct{}
+
+func (TLSSniffer) Protocol() string {
+	return "tls"
+}
+
+func (TLSSniffer) SniffTCP(b []byte) (string, error) {
+	// TLS record header: content type(1) + version(2) + length(2).
+	if len(b) < 5 || b[0] != tlsHandshakeContentType {
+		return "", ErrNoClue
+	}
+	recordLen := int(binary.BigEndian.Uint16(b[3:5]))
+	if len(b) < 5+recordLen {
+		return "", ErrNoClue
+	}
+	record := b[5 : 5+recordLen]
+
+	// Handshake header: msg type(1) + length(3).
+	if len(record) < 4 || record[0] != tlsHandshakeClientHello {
+		return "", ErrNoClue
+	}
+	helloLen := int(record[1])<<16 | int(record[2])<<8 | int(record[3])
+	if len(record)-4 < helloLen {
+		return "", ErrNoClue
+	}
+	hello := record[4 : 4+helloLen]
+
+	// client_version(2) + random(32) + session_id.
+	if len(hello) < 34+1 {
+		return "", ErrNoClue
+	}
+	pos := 34
+	sessionIDLen := int(hello[pos])
+	pos++
+	if len(hello) < pos+sessionIDLen+2 {
+		return "", ErrNoClue
+	}
+	pos += sessionIDLen
+
+	// cipher_suites.
+	cipherSuitesLen := int(binary.BigEndian.Uint16(hello[pos : pos+2]))
+	pos += 2
+	if len(hello) < pos+cipherSuitesLen+1 {
+		return "", ErrNoClue
+	}
+	pos += cipherSuitesLen
+
+	// compression_methods.
+	compressionMethodsLen := int(hello[pos])
+	pos++
+	if len(hello) < pos+compressionMethodsLen+2 {
+		return "", ErrNoClue
+	}
+	pos += compressionMethodsLen
+
+	// extensions.
+	extensionsLen := int(binary.BigEndian.Uint16(hello[pos : pos+2]))
+	pos += 2
+	if len(hello) < pos+extensionsLen {
+		return "", ErrNoClue
+	}
+	extensions := hello[pos : pos+extensionsLen]
+
+	for len(extensions) >= 4 {
+		extType := binary.BigEndian.Uint16(extensions[0:2])
+		extLen := int(binary.BigEndian.Uint16(extensions[2:4]))
+		if len(extensions) < 4+extLen {
+			return "", ErrNoClue
+		}
+		extData := extensions[4 : 4+extLen]
+		extensions = extensions[4+extLen:]
+
+		if extType != tlsExtensionServerName {
+			continue
+		}
+
+		if host, ok := parseServerNameExtension(extData); ok {
+			return host, nil
+		}
+	}
+
+	return "", ErrNoClue
+}
+
+// parseServerNameExtension reads a server_name_list and returns the first
+// host_name entry - in practice TLS clients only ever send one.
+func parseServerNameExtension(data []byte) (string, bool) {
+	if len(data) < 2 {
+		return "", false
+	}
+	listLen := int(binary.BigEndian.Uint16(data[0:2]))
+	list := data[2:]
+	if len(list) < listLen {
+		return "", false
+	}
+	list = list[:listLen]
+
+	for len(list) >= 3 {
+		nameType := list[0]
+		nameLen := int(binary.BigEndian.Uint16(list[1:3]))
+		if len(list) < 3+nameLen {
+			return "", false
+		}
+		name := list[3 : 3+nameLen]
+		list = list[3+nameLen:]
+
+		if nameType == tlsServerNameTypeHost {
+			return string(name), true
+		}
+	}
+
+	return "", false
+}
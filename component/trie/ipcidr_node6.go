@@ -0,0 +1,43 @@
+package trie
+
+// ipCidrNode6 is a single level of the IPv6 side of an IpCidrTrie, keyed
+// by a 16-bit address group (0..65535). Unlike IpCidrNode's dense
+// [256]*IpCidrNode array, children here are held in a map - a dense
+// array at this width would be 65536 pointers per node - so maxValue is
+// carried explicitly to let addChild/getChild reject an out-of-range
+// group value instead of relying on the array bounds the v4 side gets
+// for free from its type.
+type ipCidrNode6 struct {
+	Tag      bool
+	maxValue uint32
+	child    map[uint32]*ipCidrNode6
+}
+
+const ipv6GroupMax = 0xFFFF
+
+func newIpCidrNode6(tag bool) *ipCidrNode6 {
+	return &ipCidrNode6{
+		Tag:      tag,
+		maxValue: ipv6GroupMax,
+		child:    map[uint32]*ipCidrNode6{},
+	}
+}
+
+func (n *ipCidrNode6) addChild(value uint32) {
+	if value > n.maxValue {
+		return
+	}
+	n.child[value] = newIpCidrNode6(false)
+}
+
+func (n *ipCidrNode6) hasChild(value uint32) bool {
+	return !n.Tag && value <= n.maxValue && n.child[value] != nil
+}
+
+func (n *ipCidrNode6) getChild(value uint32) *ipCidrNode6 {
+	if !n.Tag && value <= n.maxValue {
+		return n.child[value]
+	}
+
+	return nil
+}
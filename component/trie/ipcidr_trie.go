@@ -2,8 +2,7 @@ package trie
 
 import (
 	"errors"
-	"strconv"
-	"strings"
+	"net"
 )
 
 var (
@@ -11,156 +10,217 @@ var (
 	ErrInvalidIpCidrFormat = errors.New("invalid ip cidr format")
 )
 
-const big = 0xFFFFFF
-
+// IpCidrTrie indexes IPv4 and IPv6 CIDRs in two independent tries: v4root
+// is keyed a byte (8 bits) at a time in IpCidrNode's dense [256]*IpCidrNode
+// array, v6root a 16-bit group at a time in ipCidrNode6's sparse map - a
+// dense array at that width would be 65536 pointers per node. AddIpCidr
+// and IsContain dispatch on the address's family so a single trie
+// instance can hold both kinds of CIDR at once.
 type IpCidrTrie struct {
-	root IpCidrNode
+	v4root *IpCidrNode
+	v6root *ipCidrNode6
 }
 
 func NewIpCidrTrie() *IpCidrTrie {
 	return &IpCidrTrie{
-		root: *NewIpCidrNode(false, true),
+		v4root: NewIpCidrNode(false, true),
+		v6root: newIpCidrNode6(false),
 	}
 }
 
-func (trie *IpCidrTrie) AddIpCidr(ipCidr string) error {
-	subIpCidr, subCidr, err := splitSubIpCidr(ipCidr)
-	if err != nil {
-		return err
-	}
-	for _, sub := range subIpCidr {
-		addIpCidr(trie, sub, subCidr/8)
+// AddIpCidr adds ipNet to whichever of the v4/v6 tries matches its
+// address family.
+func (trie *IpCidrTrie) AddIpCidr(ipNet *net.IPNet) error {
+	if ipNet == nil {
+		return ErrInvalidIpCidrFormat
 	}
 
-	return nil
-}
-func (trie *IpCidrTrie) IsContain(ip string) bool {
-	values := validAndObtainIp(ip)
-	if values == nil {
-		return false
+	ones, bits := ipNet.Mask.Size()
+	if bits == 0 {
+		// Size returns 0, 0 for a mask that isn't a sequence of 1 bits
+		// followed by 0 bits, e.g. one built by hand with CIDRMask(ones, bits)
+		// where ones > bits.
+		return ErrInvalidIpCidrFormat
 	}
-	return search(&trie.root, values) != nil
 
-}
-func validAndObtainIp(ip string) []uint8 {
-	p := make([]uint8, 4)
-	for i := 0; i < 4; i++ {
-		if len(ip) == 0 {
-			return nil
+	switch bits {
+	case 32:
+		ip4 := ipNet.IP.To4()
+		if ip4 == nil {
+			return ErrInvalidIpFormat
 		}
-		if i > 0 {
-			if ip[0] != '.' {
-				return nil
-			}
-			ip = ip[1:]
+		subGroups, groupCount := splitSubGroupCidr(toGroups(ip4, 8), ones, 8)
+		for _, sub := range subGroups {
+			addIpCidr4(trie.v4root, sub, groupCount)
 		}
-		n, c, ok := dtoi(ip)
-		if !ok || n > 0xFF {
-			return nil
+	case 128:
+		ip6 := ipNet.IP.To16()
+		if ip6 == nil || ipNet.IP.To4() != nil {
+			return ErrInvalidIpFormat
 		}
-		ip = ip[c:]
-		p[i] = uint8(n)
-	}
-	return p
-}
-func dtoi(s string) (n int, i int, ok bool) {
-	n = 0
-	for i = 0; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
-		n = n*10 + int(s[i]-'0')
-		if n >= big {
-			return big, i, false
+		subGroups, groupCount := splitSubGroupCidr(toGroups(ip6, 16), ones, 16)
+		for _, sub := range subGroups {
+			addIpCidr6(trie.v6root, sub, groupCount)
 		}
+	default:
+		return ErrInvalidIpCidrFormat
 	}
-	if i == 0 {
-		return 0, 0, false
-	}
-	return n, i, true
+
+	return nil
 }
 
-/**
-Divide an ip cidr into multiple ip cidr whose subnet mask length is a multiple of 8
-*/
-func splitSubIpCidr(ipCidr string) ([][4]uint8, int, error) {
-	p := strings.Split(ipCidr, "/")
-	if len(p) != 2 {
-		return nil, 0, ErrInvalidIpCidrFormat
-	}
-	uint8Ip := validAndObtainIp(p[0])
-	if uint8Ip == nil {
-		return nil, 0, ErrInvalidIpFormat
-	}
-	cidr, err := strconv.Atoi(p[1])
-	if err != nil || (cidr < 0 || cidr > 32) {
-		return nil, 0, ErrInvalidIpCidrFormat
-	}
-	if cidr == 0 {
-		return make([][4]uint8, 1), 0, nil
+// IsContain reports whether ip falls within any CIDR previously added to
+// trie, checking the v4 or v6 side depending on ip's address family.
+func (trie *IpCidrTrie) IsContain(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		return search4(trie.v4root, toGroups(ip4, 8)) != nil
 	}
 
-	cidrIndex := cidr / 8
-	subIpCidr := make([][4]uint8, 0)
+	ip6 := ip.To16()
+	if ip6 == nil {
+		return false
+	}
+	return search6(trie.v6root, toGroups(ip6, 16)) != nil
+}
 
-	lastIndexCidrNum := cidr % 8
-	if lastIndexCidrNum == 0 {
-		index := cidrIndex
-		if cidrIndex > 3 {
-			index = 3
+// toGroups splits ip's bytes into big-endian groups of groupBits bits
+// each - 8 for v4's per-byte groups, 16 for v6's per-uint16 groups.
+func toGroups(ip net.IP, groupBits int) []uint32 {
+	groupBytes := groupBits / 8
+	groups := make([]uint32, len(ip)/groupBytes)
+	for i := range groups {
+		var v uint32
+		for b := 0; b < groupBytes; b++ {
+			v = v<<8 | uint32(ip[i*groupBytes+b])
 		}
-		ipCidr := [4]uint8{}
-		for i := 0; i <= index; i++ {
-			ipCidr[i] = uint8Ip[i]
+		groups[i] = v
+	}
+	return groups
+}
+
+// splitSubGroupCidr divides an address's groups into multiple CIDRs whose
+// prefix length is a multiple of groupBits, the way the original
+// IPv4-only splitSubIpCidr split a /n prefix into byte-aligned sub-CIDRs,
+// generalized so the same arithmetic drives both the 8-bit (v4) and
+// 16-bit (v6) tries. A prefix that isn't group-aligned becomes
+// 2^(groupBits - prefixBits%groupBits) sub-CIDRs covering its whole range. The returned int is the number of leading groups
+// that matter for matching (what callers feed to addIpCidr4/addIpCidr6
+// as groupCount).
+func splitSubGroupCidr(groups []uint32, prefixBits, groupBits int) ([][]uint32, int) {
+	groupCount := len(groups)
+	groupMask := uint32(1)<<uint(groupBits) - 1
+
+	if prefixBits == 0 {
+		return [][]uint32{make([]uint32, groupCount)}, 0
+	}
+
+	groupIndex := prefixBits / groupBits
+	remainder := prefixBits % groupBits
+
+	if remainder == 0 {
+		index := groupIndex
+		if index > groupCount-1 {
+			index = groupCount - 1
 		}
-		subIpCidr = append(subIpCidr, ipCidr)
-		return subIpCidr, cidrIndex * 8, nil
+		sub := make([]uint32, groupCount)
+		copy(sub, groups[:index+1])
+		return [][]uint32{sub}, groupIndex
 	}
 
-	subIpCidrNum := uint8Ip[cidrIndex] & (0xFF >> lastIndexCidrNum)
-	var endCidr uint8 = 0
+	subGroupNum := uint32(1) << uint(groupBits-remainder)
+	base := groups[groupIndex] & (groupMask << uint(groupBits-remainder) & groupMask)
 
-	endCidr = uint8Ip[cidrIndex] & uint8(0xFF<<(8-lastIndexCidrNum))
-	for i := 0; i < int(subIpCidrNum); i++ {
-		j := 0
-		sub := [4]uint8{}
-		for ; j < cidrIndex; j++ {
-			sub[j] = 0xff & uint8Ip[j]
-		}
-		sub[j] = endCidr + uint8(i)
-		subIpCidr = append(subIpCidr, sub)
+	subGroups := make([][]uint32, 0, subGroupNum)
+	for i := uint32(0); i < subGroupNum; i++ {
+		sub := make([]uint32, groupCount)
+		copy(sub, groups[:groupIndex])
+		sub[groupIndex] = base + i
+		subGroups = append(subGroups, sub)
 	}
-	return subIpCidr, (cidrIndex + 1) * 8, nil
+	return subGroups, groupIndex + 1
 }
 
-func addIpCidr(trie *IpCidrTrie, ip [4]uint8, cidrByteSize int) {
-	node := trie.root.getChild(ip[0])
+func addIpCidr4(root *IpCidrNode, groups []uint32, groupCount int) {
+	if groupCount == 0 {
+		root.Tag = true
+		cleanChild(root)
+		return
+	}
+
+	node := root.getChild(uint8(groups[0]))
 
-	for i := 1; i < cidrByteSize; i++ {
+	for i := 1; i < groupCount; i++ {
 		if node.Tag {
 			return
 		}
-		if !node.hasChild(ip[i]) {
-			node.addChild(ip[i])
+		v := uint8(groups[i])
+		if !node.hasChild(v) {
+			node.addChild(v)
 		}
-		node = node.getChild(ip[i])
+		node = node.getChild(v)
 	}
 	node.Tag = true
 	cleanChild(node)
 }
+
 func cleanChild(node *IpCidrNode) {
 	for i := 0; i < len(node.child); i++ {
 		node.child[i] = nil
 	}
 }
 
-func search(root *IpCidrNode, partValues []uint8) *IpCidrNode {
-	node := root.getChild(partValues[0])
+func search4(root *IpCidrNode, groups []uint32) *IpCidrNode {
+	if root.Tag {
+		return root
+	}
+
+	node := root.getChild(uint8(groups[0]))
+	if node.Tag {
+		return node
+	}
+	for _, v := range groups[1:] {
+		if !node.hasChild(uint8(v)) {
+			return nil
+		}
+		node = node.getChild(uint8(v))
+
+		if node.Tag {
+			return node
+		}
+	}
+	return nil
+}
+
+// addIpCidr6 mirrors addIpCidr4, except ipCidrNode6's root (unlike
+// IpCidrNode's) isn't pre-populated with every possible child - a dense
+// array at 16 bits wide is what the map avoids in the first place - so
+// every level, including the first, is created lazily here.
+func addIpCidr6(root *ipCidrNode6, groups []uint32, groupCount int) {
+	node := root
+	for i := 0; i < groupCount; i++ {
+		if node.Tag {
+			return
+		}
+		v := groups[i]
+		if !node.hasChild(v) {
+			node.addChild(v)
+		}
+		node = node.getChild(v)
+	}
+	node.Tag = true
+	node.child = map[uint32]*ipCidrNode6{}
+}
+
+func search6(root *ipCidrNode6, groups []uint32) *ipCidrNode6 {
+	node := root
 	if node.Tag {
 		return node
 	}
-	for _, value := range partValues[1:] {
-		if !node.hasChild(value) {
+	for _, v := range groups {
+		if !node.hasChild(v) {
 			return nil
 		}
-		node = node.getChild(value)
+		node = node.getChild(v)
 
 		if node.Tag {
 			return node
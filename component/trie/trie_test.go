@@ -1,37 +1,65 @@
 package trie
 
+import "net"
 import "testing"
 import "github.com/stretchr/testify/assert"
 
+// ipNet builds a *net.IPNet from ip/ones without going through
+// net.ParseCIDR, which would mask ip down to its network address -
+// AddIpCidr's splitSubGroupCidr is exercised below against the same
+// (possibly host-bit-carrying) values the old string-based API was
+// exercised with.
+func ipNet(ip string, ones, bits int) *net.IPNet {
+	return &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(ones, bits)}
+}
+
 func TestAddSuccess(t *testing.T) {
 	trie := NewIpCidrTrie()
-	err := trie.AddIpCidr("10.0.0.2/16")
+	err := trie.AddIpCidr(ipNet("10.0.0.2", 16, 32))
 	assert.Equal(t, nil, err)
 }
+
 func TestAddFail(t *testing.T) {
 	trie := NewIpCidrTrie()
-	err := trie.AddIpCidr("333.00.23.2/23")
-	assert.Equal(t, ErrInvalidIpFormat, err)
-
-	err = trie.AddIpCidr("22.3.34.2/222")
+	err := trie.AddIpCidr(nil)
 	assert.Equal(t, ErrInvalidIpCidrFormat, err)
 
-	err = trie.AddIpCidr("2.2.2.2")
+	err = trie.AddIpCidr(ipNet("22.3.34.2", 222, 32))
 	assert.Equal(t, ErrInvalidIpCidrFormat, err)
+
+	err = trie.AddIpCidr(&net.IPNet{IP: net.ParseIP("not-an-ip"), Mask: net.CIDRMask(23, 32)})
+	assert.Equal(t, ErrInvalidIpFormat, err)
 }
 
 func TestSearch(t *testing.T) {
 	trie := NewIpCidrTrie()
-	assert.NoError(t, trie.AddIpCidr("129.2.36.0/16"))
-	assert.NoError(t, trie.AddIpCidr("10.2.36.0/18"))
-	assert.NoError(t, trie.AddIpCidr("16.2.23.0/24"))
-	assert.NoError(t, trie.AddIpCidr("11.2.13.2/26"))
-	assert.NoError(t, trie.AddIpCidr("55.5.6.3/8"))
-	assert.NoError(t, trie.AddIpCidr("66.23.25.4/6"))
-	assert.Equal(t, true, trie.IsContain("129.2.3.65"))
-	assert.Equal(t, false, trie.IsContain("15.2.3.1"))
-	assert.Equal(t, true, trie.IsContain("11.2.13.1"))
-	assert.Equal(t, true, trie.IsContain("55.0.0.0"))
-	assert.Equal(t, true, trie.IsContain("64.0.0.0"))
-	assert.Equal(t, false, trie.IsContain("128.0.0.0"))
+	assert.NoError(t, trie.AddIpCidr(ipNet("129.2.36.0", 16, 32)))
+	assert.NoError(t, trie.AddIpCidr(ipNet("10.2.36.0", 18, 32)))
+	assert.NoError(t, trie.AddIpCidr(ipNet("16.2.23.0", 24, 32)))
+	assert.NoError(t, trie.AddIpCidr(ipNet("11.2.13.2", 26, 32)))
+	assert.NoError(t, trie.AddIpCidr(ipNet("55.5.6.3", 8, 32)))
+	assert.NoError(t, trie.AddIpCidr(ipNet("66.23.25.4", 6, 32)))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("129.2.3.65")))
+	assert.Equal(t, false, trie.IsContain(net.ParseIP("15.2.3.1")))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("11.2.13.1")))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("55.0.0.0")))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("64.0.0.0")))
+	assert.Equal(t, false, trie.IsContain(net.ParseIP("128.0.0.0")))
+}
+
+func TestSearchV4ZeroCidr(t *testing.T) {
+	trie := NewIpCidrTrie()
+	assert.NoError(t, trie.AddIpCidr(ipNet("0.0.0.0", 0, 32)))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("8.8.8.8")))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("255.255.255.255")))
+}
+
+func TestSearchV6(t *testing.T) {
+	trie := NewIpCidrTrie()
+	assert.NoError(t, trie.AddIpCidr(ipNet("2001:db8::", 32, 128)))
+	assert.NoError(t, trie.AddIpCidr(ipNet("::1", 128, 128)))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("2001:db8::1")))
+	assert.Equal(t, true, trie.IsContain(net.ParseIP("::1")))
+	assert.Equal(t, false, trie.IsContain(net.ParseIP("2001:db9::1")))
+	assert.Equal(t, false, trie.IsContain(net.ParseIP("::2")))
 }
@@ -74,6 +74,19 @@ type Metadata struct {
 	DstPort  string  `json:"destinationPort"`
 	AddrType int     `json:"-"`
 	Host     string  `json:"host"`
+	// InName is the name of the inbound listener this connection arrived
+	// on (as configured in the listener's config block), used to report
+	// which listener a connection came in through.
+	InName string `json:"inName,omitempty"`
+	// PreferRulesName names a sub-rules group (see Config.SubRules) that
+	// should be evaluated ahead of the main rule list for this connection,
+	// so a single listener can be bound to its own rule subtree.
+	PreferRulesName string `json:"-"`
+	// ProcessPath is the absolute path of the local process that owns this
+	// connection's source socket, resolved by tunnel.match before rule
+	// iteration when find-process-mode calls for it. Empty until resolved,
+	// and left empty entirely when find-process-mode is "off".
+	ProcessPath string `json:"processPath,omitempty"`
 }
 
 func (m *Metadata) RemoteAddress() string {
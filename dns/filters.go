@@ -2,11 +2,32 @@ package dns
 
 import (
 	"net"
+	"strings"
 
+	"github.com/Dreamacro/clash/component/geodata"
 	"github.com/Dreamacro/clash/component/mmdb"
 	"github.com/Dreamacro/clash/component/trie"
 )
 
+// geositePrefix marks a FallbackFilter/Policy domain entry as a geosite
+// category selector (e.g. "geosite:cn", "geosite:!geolocation-cn") rather
+// than a literal domain.
+const geositePrefix = "geosite:"
+
+func isGeoSiteSelector(selector string) bool {
+	return strings.HasPrefix(selector, geositePrefix)
+}
+
+// parseGeoSiteSelector splits a "geosite:category" selector into its
+// category and whether it was negated with a leading "!".
+func parseGeoSiteSelector(selector string) (category string, inverse bool) {
+	category = strings.TrimPrefix(selector, geositePrefix)
+	if strings.HasPrefix(category, "!") {
+		return strings.TrimPrefix(category, "!"), true
+	}
+	return category, false
+}
+
 type fallbackIPFilter interface {
 	Match(net.IP) bool
 }
@@ -44,3 +65,25 @@ func NewDomainFilter(domains []string) *domainFilter {
 func (df *domainFilter) Match(domain string) bool {
 	return df.tree.Search(domain) != nil
 }
+
+// geositeFilter matches a domain against a geosite category loaded from
+// GeoSite.dat, instead of requiring every domain to be listed out by hand.
+type geositeFilter struct {
+	matcher *geodata.Matcher
+	inverse bool
+}
+
+// newGeositeFilter builds a geositeFilter from a "geosite:category"
+// selector, loading (and memoising) that category via component/geodata.
+func newGeositeFilter(selector string) (*geositeFilter, error) {
+	category, inverse := parseGeoSiteSelector(selector)
+	matcher, err := geodata.LoadMatcher(category)
+	if err != nil {
+		return nil, err
+	}
+	return &geositeFilter{matcher: matcher, inverse: inverse}, nil
+}
+
+func (gf *geositeFilter) Match(domain string) bool {
+	return gf.matcher.Match(domain) != gf.inverse
+}
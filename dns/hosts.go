@@ -0,0 +1,48 @@
+package dns
+
+import (
+	"net/netip"
+
+	D "github.com/miekg/dns"
+)
+
+// HostValue is what a static entry in Config.Hosts resolves to: either a
+// fixed set of addresses to answer A/AAAA questions with directly, or a
+// CNAME target to resolve through the normal policy/fallback path.
+type HostValue struct {
+	IPs    []netip.Addr
+	Domain string
+}
+
+// NewIPHostValue builds a HostValue that answers A/AAAA questions with ips
+// directly, without consulting any upstream nameserver.
+func NewIPHostValue(ips ...netip.Addr) HostValue {
+	return HostValue{IPs: ips}
+}
+
+// NewDomainHostValue builds a HostValue that redirects to domain via CNAME,
+// so upstream policy/fallback selection still applies to the new name.
+func NewDomainHostValue(domain string) HostValue {
+	return HostValue{Domain: domain}
+}
+
+// IsDomain reports whether this entry is a CNAME redirect rather than a
+// fixed set of addresses.
+func (v HostValue) IsDomain() bool {
+	return v.Domain != ""
+}
+
+// IPsFor filters IPs down to the family a question of qType can use: IPv4
+// addresses for TypeA, IPv6 addresses for TypeAAAA.
+func (v HostValue) IPsFor(qType uint16) []netip.Addr {
+	var ips []netip.Addr
+	for _, ip := range v.IPs {
+		switch {
+		case qType == D.TypeA && ip.Is4():
+			ips = append(ips, ip)
+		case qType == D.TypeAAAA && ip.Is6() && !ip.Is4In6():
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/Dreamacro/clash/common/cache"
 	"github.com/Dreamacro/clash/common/picker"
 	"github.com/Dreamacro/clash/component/fakeip"
+	"github.com/Dreamacro/clash/component/geodata"
 	"github.com/Dreamacro/clash/component/resolver"
 	"github.com/Dreamacro/clash/component/trie"
+	"github.com/Dreamacro/clash/log"
 
 	D "github.com/miekg/dns"
 	"golang.org/x/sync/singleflight"
@@ -31,14 +34,25 @@ type result struct {
 
 type Resolver struct {
 	ipv6                  bool
-	hosts                 *trie.DomainTrie
+	hosts                 *trie.DomainTrie // domain -> HostValue
 	main                  []dnsClient
 	fallback              []dnsClient
+	proxyServer           []dnsClient
 	fallbackDomainFilters []fallbackDomainFilter
 	fallbackIPFilters     []fallbackIPFilter
 	group                 singleflight.Group
 	lruCache              *cache.LruCache
 	policy                *trie.DomainTrie
+	geoSitePolicies       []geoSitePolicy
+}
+
+// geoSitePolicy is a Policy entry keyed by a geosite category selector
+// (e.g. "geosite:cn") instead of a literal domain pattern, since those
+// don't fit trie.DomainTrie's by-domain indexing.
+type geoSitePolicy struct {
+	matcher *geodata.Matcher
+	inverse bool
+	clients []dnsClient
 }
 
 // ResolveIP request with TypeA and TypeAAAA, priority return TypeA
@@ -76,6 +90,41 @@ func (r *Resolver) ResolveIPv6(host string) (ip net.IP, err error) {
 	return r.resolveIP(host, D.TypeAAAA)
 }
 
+// proxyServerClients returns the dedicated ProxyServer nameserver group, or
+// Main if none was configured, so a ProxyServer group is optional to set up.
+func (r *Resolver) proxyServerClients() []dnsClient {
+	if len(r.proxyServer) != 0 {
+		return r.proxyServer
+	}
+	return r.main
+}
+
+// ResolveProxyServerHost resolves an upstream proxy server's hostname
+// through the dedicated ProxyServer nameserver group instead of Main, so
+// dialing the proxy itself doesn't depend on whatever Main is bootstrapped
+// through (fake-ip, a tun-redirected resolver, ...) and can't deadlock
+// resolving the very proxy it's supposed to tunnel through.
+func (r *Resolver) ResolveProxyServerHost(host string) (ip net.IP, err error) {
+	if ip = net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+
+	query := &D.Msg{}
+	query.SetQuestion(D.Fqdn(host), D.TypeA)
+
+	msg, err := r.batchExchange(r.proxyServerClients(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := msgToIP(msg)
+	if len(ips) == 0 {
+		return nil, resolver.ErrIPNotFound
+	}
+
+	return ips[rand.Intn(len(ips))], nil
+}
+
 func (r *Resolver) shouldIPFallback(ip net.IP) bool {
 	for _, filter := range r.fallbackIPFilters {
 		if filter.Match(ip) {
@@ -85,13 +134,30 @@ func (r *Resolver) shouldIPFallback(ip net.IP) bool {
 	return false
 }
 
+// maxHostsCNAMEDepth bounds how many hosts-file CNAME hops exchangeFromHosts
+// will follow. Hosts entries are user-supplied config, and a cycle
+// (a.com -> b.com -> a.com, or even a.com -> a.com) would otherwise recurse
+// through Exchange without limit and crash the process with a stack
+// overflow. Past the limit the hosts entry is ignored and the name is
+// resolved through the normal cache/upstream path instead.
+const maxHostsCNAMEDepth = 32
+
 // Exchange a batch of dns request, and it use cache
 func (r *Resolver) Exchange(m *D.Msg) (msg *D.Msg, err error) {
+	return r.exchange(m, 0)
+}
+
+func (r *Resolver) exchange(m *D.Msg, cnameDepth int) (msg *D.Msg, err error) {
 	if len(m.Question) == 0 {
 		return nil, errors.New("should have one question at least")
 	}
 
 	q := m.Question[0]
+
+	if msg, ok := r.exchangeFromHosts(m, q, cnameDepth); ok {
+		return msg, nil
+	}
+
 	cache, expireTime, hit := r.lruCache.GetWithExpire(q.String())
 	if hit {
 		now := time.Now()
@@ -143,6 +209,70 @@ func (r *Resolver) exchangeWithoutCache(m *D.Msg) (msg *D.Msg, err error) {
 	return
 }
 
+// exchangeFromHosts answers m straight from the static Hosts trie, ahead of
+// the cache and singleflight layers, since a hosts entry never expires and
+// never needs request coalescing. A CNAME entry rewrites the question name
+// and recurses through exchange so upstream policy/fallback selection still
+// applies to the new name. cnameDepth counts the CNAME hops taken so far and
+// caps out at maxHostsCNAMEDepth: past it, ok is false and the caller
+// resolves the name through the cache/upstream path, so a cyclic hosts
+// config ends in a normal lookup instead of recursing forever.
+func (r *Resolver) exchangeFromHosts(m *D.Msg, q D.Question, cnameDepth int) (msg *D.Msg, ok bool) {
+	if r.hosts == nil || (q.Qtype != D.TypeA && q.Qtype != D.TypeAAAA) {
+		return nil, false
+	}
+
+	record := r.hosts.Search(r.msgToDomain(m))
+	if record == nil {
+		return nil, false
+	}
+	host := record.Data.(HostValue)
+
+	if host.IsDomain() {
+		if cnameDepth >= maxHostsCNAMEDepth {
+			return nil, false
+		}
+
+		cnameMsg := m.Copy()
+		cnameMsg.Question[0].Name = D.Fqdn(host.Domain)
+
+		answer, err := r.exchange(cnameMsg, cnameDepth+1)
+		if err != nil {
+			return nil, false
+		}
+
+		msg = m.Copy()
+		msg.Answer = append(msg.Answer, &D.CNAME{
+			Hdr:    D.RR_Header{Name: q.Name, Rrtype: D.TypeCNAME, Class: D.ClassINET, Ttl: 60},
+			Target: D.Fqdn(host.Domain),
+		})
+		msg.Answer = append(msg.Answer, answer.Answer...)
+		return msg, true
+	}
+
+	ips := host.IPsFor(q.Qtype)
+	if len(ips) == 0 {
+		return nil, false
+	}
+
+	msg = m.Copy()
+	for _, ip := range ips {
+		if q.Qtype == D.TypeA {
+			msg.Answer = append(msg.Answer, &D.A{
+				Hdr: D.RR_Header{Name: q.Name, Rrtype: D.TypeA, Class: D.ClassINET, Ttl: 60},
+				A:   ip.AsSlice(),
+			})
+		} else {
+			msg.Answer = append(msg.Answer, &D.AAAA{
+				Hdr:  D.RR_Header{Name: q.Name, Rrtype: D.TypeAAAA, Class: D.ClassINET, Ttl: 60},
+				AAAA: ip.AsSlice(),
+			})
+		}
+	}
+
+	return msg, true
+}
+
 func (r *Resolver) batchExchange(clients []dnsClient, m *D.Msg) (msg *D.Msg, err error) {
 	fast, ctx := picker.WithTimeout(context.Background(), resolver.DefaultDNSTimeout)
 	for _, client := range clients {
@@ -172,21 +302,24 @@ func (r *Resolver) batchExchange(clients []dnsClient, m *D.Msg) (msg *D.Msg, err
 }
 
 func (r *Resolver) matchPolicy(m *D.Msg) []dnsClient {
-	if r.policy == nil {
-		return nil
-	}
-
 	domain := r.msgToDomain(m)
 	if domain == "" {
 		return nil
 	}
 
-	record := r.policy.Search(domain)
-	if record == nil {
-		return nil
+	if r.policy != nil {
+		if record := r.policy.Search(domain); record != nil {
+			return record.Data.([]dnsClient)
+		}
+	}
+
+	for _, gp := range r.geoSitePolicies {
+		if gp.matcher.Match(domain) != gp.inverse {
+			return gp.clients
+		}
 	}
 
-	return record.Data.([]dnsClient)
+	return nil
 }
 
 func (r *Resolver) shouldOnlyQueryFallback(m *D.Msg) bool {
@@ -310,19 +443,33 @@ type FallbackFilter struct {
 
 type Config struct {
 	Main, Fallback []NameServer
-	Default        []NameServer
+	// Default bootstraps resolution of any DoH/DoT/DoQ hostname configured
+	// in Main/Fallback/Policy/ProxyServer, so it must be plain IP:port
+	// nameservers — there's nothing left to resolve them with otherwise.
+	Default []NameServer
+	// ProxyServer resolves upstream proxy server hostnames, kept separate
+	// from Main so it isn't affected by fake-ip/EnhancedMode redirects.
+	ProxyServer    []NameServer
 	IPv6           bool
 	EnhancedMode   EnhancedMode
 	FallbackFilter FallbackFilter
 	Pool           *fakeip.Pool
-	Hosts          *trie.DomainTrie
-	Policy         map[string]NameServer
+	// Hosts maps a domain to a HostValue: either the fixed IPs to answer
+	// with, or a CNAME target to resolve through the normal Main/Policy/
+	// Fallback path.
+	Hosts  *trie.DomainTrie
+	Policy map[string]NameServer
 }
 
+// defaultResolverCacheSize bounds the boot resolver's LRU. It only ever
+// resolves the handful of DoH/DoT/DoQ hostnames configured in Main/
+// Fallback/Policy/ProxyServer, so it doesn't need Main's cache budget.
+const defaultResolverCacheSize = 64
+
 func NewResolver(config Config) *Resolver {
 	defaultResolver := &Resolver{
 		main:     transform(config.Default, nil),
-		lruCache: cache.NewLRUCache(cache.WithSize(4096), cache.WithStale(true)),
+		lruCache: cache.NewLRUCache(cache.WithSize(defaultResolverCacheSize), cache.WithStale(true)),
 	}
 
 	r := &Resolver{
@@ -336,10 +483,40 @@ func NewResolver(config Config) *Resolver {
 		r.fallback = transform(config.Fallback, defaultResolver)
 	}
 
+	if len(config.ProxyServer) != 0 {
+		r.proxyServer = transform(config.ProxyServer, defaultResolver)
+	}
+
 	if len(config.Policy) != 0 {
 		r.policy = trie.New()
-		for domain, nameserver := range config.Policy {
-			r.policy.Insert(domain, transform([]NameServer{nameserver}, defaultResolver))
+
+		// Policy is a map, so iterate its keys in a fixed order: geosite
+		// selectors can't be deduplicated by the trie the way domain
+		// patterns are, and a stable order keeps matchPolicy deterministic
+		// when two categories could otherwise match the same domain.
+		selectors := make([]string, 0, len(config.Policy))
+		for selector := range config.Policy {
+			selectors = append(selectors, selector)
+		}
+		sort.Strings(selectors)
+
+		for _, selector := range selectors {
+			clients := transform([]NameServer{config.Policy[selector]}, defaultResolver)
+			if isGeoSiteSelector(selector) {
+				category, inverse := parseGeoSiteSelector(selector)
+				matcher, err := geodata.LoadMatcher(category)
+				if err != nil {
+					log.Errorln("[DNS] policy %s: %s", selector, err.Error())
+					continue
+				}
+				r.geoSitePolicies = append(r.geoSitePolicies, geoSitePolicy{
+					matcher: matcher,
+					inverse: inverse,
+					clients: clients,
+				})
+				continue
+			}
+			r.policy.Insert(selector, clients)
 		}
 	}
 
@@ -355,7 +532,27 @@ func NewResolver(config Config) *Resolver {
 	r.fallbackIPFilters = fallbackIPFilters
 
 	if len(config.FallbackFilter.Domain) != 0 {
-		fallbackDomainFilters := []fallbackDomainFilter{NewDomainFilter(config.FallbackFilter.Domain)}
+		var fallbackDomainFilters []fallbackDomainFilter
+		var plainDomains []string
+
+		for _, selector := range config.FallbackFilter.Domain {
+			if !isGeoSiteSelector(selector) {
+				plainDomains = append(plainDomains, selector)
+				continue
+			}
+
+			gf, err := newGeositeFilter(selector)
+			if err != nil {
+				log.Errorln("[DNS] fallback-filter %s: %s", selector, err.Error())
+				continue
+			}
+			fallbackDomainFilters = append(fallbackDomainFilters, gf)
+		}
+
+		if len(plainDomains) != 0 {
+			fallbackDomainFilters = append(fallbackDomainFilters, NewDomainFilter(plainDomains))
+		}
+
 		r.fallbackDomainFilters = fallbackDomainFilters
 	}
 
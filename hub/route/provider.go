@@ -6,8 +6,8 @@ import (
 
 	ruleProvider "github.com/Dreamacro/clash/rule/provider"
 
+	healthcheck "github.com/Dreamacro/clash/adapter/provider"
 	"github.com/Dreamacro/clash/constant/provider"
-	"github.com/Dreamacro/clash/tunnel"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -22,14 +22,14 @@ func proxyProviderRouter() http.Handler {
 		r.Get("/", getProvider)
 		r.Put("/", updateProvider)
 		r.Get("/healthcheck", healthCheckProvider)
+		r.Put("/touch", touchProvider)
 	})
 	return r
 }
 
 func getProviders(w http.ResponseWriter, r *http.Request) {
-	providers := tunnel.Providers()
 	render.JSON(w, r, render.M{
-		"providers": providers,
+		"providers": providerService{}.List(),
 	})
 }
 
@@ -39,8 +39,8 @@ func getProvider(w http.ResponseWriter, r *http.Request) {
 }
 
 func updateProvider(w http.ResponseWriter, r *http.Request) {
-	provider := r.Context().Value(CtxKeyProvider).(provider.ProxyProvider)
-	if err := provider.Update(); err != nil {
+	pd := r.Context().Value(CtxKeyProvider).(provider.ProxyProvider)
+	if err := (providerService{}).Update(pd); err != nil {
 		render.Status(r, http.StatusServiceUnavailable)
 		render.JSON(w, r, newError(err.Error()))
 		return
@@ -48,9 +48,42 @@ func updateProvider(w http.ResponseWriter, r *http.Request) {
 	render.NoContent(w, r)
 }
 
+// healthCheckOverrider is implemented by providers whose underlying
+// healthcheck.HealthCheck can be probed ad-hoc with a url/expected-status
+// override, instead of only the values from its config block.
+type healthCheckOverrider interface {
+	HealthCheckWithOptions(opts healthcheck.HealthCheckOptions)
+}
+
 func healthCheckProvider(w http.ResponseWriter, r *http.Request) {
-	provider := r.Context().Value(CtxKeyProvider).(provider.ProxyProvider)
-	provider.HealthCheck()
+	pd := r.Context().Value(CtxKeyProvider).(provider.ProxyProvider)
+
+	expectedStatus, err := healthcheck.ParseStatusRange(r.URL.Query().Get("expected-status"))
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, newError(err.Error()))
+		return
+	}
+
+	(providerService{}).HealthCheck(pd, healthcheck.HealthCheckOptions{
+		URL:            r.URL.Query().Get("url"),
+		ExpectedStatus: expectedStatus,
+	})
+	render.NoContent(w, r)
+}
+
+// toucher is implemented by providers whose underlying healthcheck.HealthCheck
+// tracks a lazy-load TTL that can be extended without running a probe.
+type toucher interface {
+	Touch()
+}
+
+// touchProvider extends a provider's lazy-load TTL on demand, so the
+// dashboard can keep a provider "warm" without that bookkeeping leaking
+// into GET /providers/proxies, which stays side-effect free.
+func touchProvider(w http.ResponseWriter, r *http.Request) {
+	pd := r.Context().Value(CtxKeyProvider).(provider.ProxyProvider)
+	(providerService{}).Touch(pd)
 	render.NoContent(w, r)
 }
 
@@ -65,8 +98,7 @@ func parseProviderName(next http.Handler) http.Handler {
 func findProviderByName(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		name := r.Context().Value(CtxKeyProviderName).(string)
-		providers := tunnel.Providers()
-		provider, exist := providers[name]
+		provider, exist := (providerService{}).Get(name)
 		if !exist {
 			render.Status(r, http.StatusNotFound)
 			render.JSON(w, r, ErrNotFound)
@@ -83,21 +115,33 @@ func ruleProviderRouter() http.Handler {
 	r.Get("/", getRuleProviders)
 	r.Route("/{name}", func(r chi.Router) {
 		r.Use(parseRuleProviderName, findRuleProviderByName)
+		r.Get("/", getRuleProvider)
+		r.Head("/", getRuleProvider)
 		r.Put("/", updateRuleProvider)
 	})
 	return r
 }
 
 func getRuleProviders(w http.ResponseWriter, r *http.Request) {
-	ruleProviders := tunnel.RuleProviders()
 	render.JSON(w, r, render.M{
-		"providers": ruleProviders,
+		"providers": ruleProviderService{}.List(),
 	})
 }
 
+// getRuleProvider reports a single rule-provider's own JSON representation,
+// which already carries updatedAt and lastError - enough for a dashboard or
+// monitoring probe to tell whether the last background pull kept the
+// ruleset fresh without having to fetch and diff the whole provider list.
+// A HEAD request runs this same handler: net/http discards the body it
+// writes, leaving just the status code and headers.
+func getRuleProvider(w http.ResponseWriter, r *http.Request) {
+	rp := r.Context().Value(CtxKeyProvider).(*ruleProvider.RuleProvider)
+	render.JSON(w, r, rp)
+}
+
 func updateRuleProvider(w http.ResponseWriter, r *http.Request) {
-	provider := r.Context().Value(CtxKeyProvider).(*ruleProvider.RuleProvider)
-	if err := (*provider).Update(); err != nil {
+	rp := r.Context().Value(CtxKeyProvider).(*ruleProvider.RuleProvider)
+	if err := (ruleProviderService{}).Update(rp); err != nil {
 		render.Status(r, http.StatusServiceUnavailable)
 		render.JSON(w, r, newError(err.Error()))
 	}
@@ -116,8 +160,7 @@ func parseRuleProviderName(next http.Handler) http.Handler {
 func findRuleProviderByName(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		name := r.Context().Value(CtxKeyProviderName).(string)
-		providers := tunnel.RuleProviders()
-		provider, exist := providers[name]
+		provider, exist := (ruleProviderService{}).Get(name)
 		if !exist {
 			render.Status(r, http.StatusNotFound)
 			render.JSON(w, r, ErrNotFound)
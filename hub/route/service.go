@@ -0,0 +1,61 @@
+package route
+
+import (
+	healthcheck "github.com/Dreamacro/clash/adapter/provider"
+	"github.com/Dreamacro/clash/constant/provider"
+	ruleProvider "github.com/Dreamacro/clash/rule/provider"
+	"github.com/Dreamacro/clash/tunnel"
+)
+
+// providerService holds the logic behind the proxy-provider routes,
+// independent of any particular transport, so the chi handlers below don't
+// re-implement it against tunnel.Providers() themselves.
+type providerService struct{}
+
+func (providerService) List() map[string]provider.ProxyProvider {
+	return tunnel.Providers()
+}
+
+func (providerService) Get(name string) (provider.ProxyProvider, bool) {
+	pd, ok := tunnel.Providers()[name]
+	return pd, ok
+}
+
+func (providerService) Update(pd provider.ProxyProvider) error {
+	return pd.Update()
+}
+
+// HealthCheck runs pd's health check, using opts to override the URL/
+// expected-status when pd supports it (see healthCheckOverrider) and
+// falling back to pd's own configured check otherwise.
+func (providerService) HealthCheck(pd provider.ProxyProvider, opts healthcheck.HealthCheckOptions) {
+	if overrider, ok := pd.(healthCheckOverrider); ok && (opts.URL != "" || opts.ExpectedStatus != nil) {
+		overrider.HealthCheckWithOptions(opts)
+		return
+	}
+	pd.HealthCheck()
+}
+
+// Touch extends pd's lazy-load TTL, if it supports touching (see toucher).
+func (providerService) Touch(pd provider.ProxyProvider) {
+	if t, ok := pd.(toucher); ok {
+		t.Touch()
+	}
+}
+
+// ruleProviderService holds the logic behind the rule-provider routes, for
+// the same reason providerService exists for proxy providers.
+type ruleProviderService struct{}
+
+func (ruleProviderService) List() map[string]*ruleProvider.RuleProvider {
+	return tunnel.RuleProviders()
+}
+
+func (ruleProviderService) Get(name string) (*ruleProvider.RuleProvider, bool) {
+	rp, ok := tunnel.RuleProviders()[name]
+	return rp, ok
+}
+
+func (ruleProviderService) Update(rp *ruleProvider.RuleProvider) error {
+	return (*rp).Update()
+}
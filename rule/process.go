@@ -1,54 +1,46 @@
 package rules
 
 import (
-	"fmt"
-	"strconv"
+	"path/filepath"
 	"strings"
 
 	"github.com/Dreamacro/clash/common/cache"
-	"github.com/Dreamacro/clash/component/process"
 	C "github.com/Dreamacro/clash/constant"
-	"github.com/Dreamacro/clash/log"
 )
 
 var processCache = cache.NewLRUCache(cache.WithAge(2), cache.WithSize(64))
 
 type Process struct {
-	adapter string
-	process string
+	adapter   string
+	process   string
 	fullMatch bool
 }
 
+// RuleType returns C.Process for a PROCESS-NAME rule (matched against the
+// process's basename) and C.ProcessPath for PROCESS-PATH (matched against
+// its full path), per ps.fullMatch.
 func (ps *Process) RuleType() C.RuleType {
+	if ps.fullMatch {
+		return C.ProcessPath
+	}
 	return C.Process
 }
 
 func (ps *Process) Match(metadata *C.Metadata) bool {
-	key := fmt.Sprintf("%s:%s:%s", metadata.NetWork.String(), metadata.SrcIP.String(), metadata.SrcPort)
-	cached, hit := processCache.Get(key)
-	if !hit {
-		srcPort, err := strconv.Atoi(metadata.SrcPort)
-		if err != nil {
-			processCache.Set(key, "")
-			return false
-		}
-
-		name, err := process.FindProcessName(metadata.NetWork.String(), metadata.SrcIP, srcPort)
-		if err != nil {
-			log.Debugln("[Rule] find process name %s error: %s", C.Process.String(), err.Error())
-		}
-
-		processCache.Set(key, name)
+	if processMode == FindProcessOff {
+		return false
+	}
 
-		cached = name
+	processPath := metadata.ProcessPath
+	if processPath == "" {
+		processPath = ResolveProcessPath(metadata)
 	}
 
-	processName := cached.(string)
 	if !ps.fullMatch {
-		processName = filepath.Base(processName)
+		processPath = filepath.Base(processPath)
 	}
 
-	return strings.EqualFold(processName, ps.process)
+	return strings.EqualFold(processPath, ps.process)
 }
 
 func (ps *Process) Adapter() string {
@@ -69,8 +61,8 @@ func NewProcess(process string, adapter string, fullMatch bool) (*Process, error
 	}
 
 	return &Process{
-		adapter: adapter,
-		process: process,
+		adapter:   adapter,
+		process:   process,
 		fullMatch: fullMatch,
 	}, nil
 }
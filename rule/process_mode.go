@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Dreamacro/clash/component/process"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/log"
+)
+
+// FindProcessMode controls when a PROCESS-NAME/PROCESS-PATH rule - and
+// tunnel.match, under FindProcessAlways - actually resolves a
+// connection's owning process, since the lookup walks every local
+// socket/process on some platforms and isn't free.
+type FindProcessMode string
+
+const (
+	// FindProcessAlways resolves metadata.ProcessPath for every connection
+	// before rule iteration even starts, so any rule (not just a process
+	// rule) can see it.
+	FindProcessAlways FindProcessMode = "always"
+	// FindProcessStrict is the default: a process is only resolved when a
+	// PROCESS-NAME/PROCESS-PATH rule is actually evaluated against it.
+	FindProcessStrict FindProcessMode = "strict"
+	// FindProcessOff never resolves a process, even for a process rule -
+	// useful on routers where the walk FindProcessName does is expensive
+	// and process rules aren't in use.
+	FindProcessOff FindProcessMode = "off"
+)
+
+func (m FindProcessMode) String() string {
+	return string(m)
+}
+
+var processMode = FindProcessStrict
+
+// SetFindProcessMode changes how eagerly process rules (and
+// tunnel.match) resolve a connection's owning process.
+func SetFindProcessMode(mode FindProcessMode) {
+	processMode = mode
+}
+
+// GetFindProcessMode returns the current FindProcessMode.
+func GetFindProcessMode() FindProcessMode {
+	return processMode
+}
+
+// ResolveProcessPath returns the absolute path of the local process that
+// owns metadata's source socket, consulting processCache first so a
+// burst of connections from the same (network, srcIP, srcPort) within
+// processCache's age window costs one lookup, not one per connection.
+// Exported so tunnel.match can resolve it eagerly under
+// FindProcessAlways instead of waiting for a process rule to trigger it.
+func ResolveProcessPath(metadata *C.Metadata) string {
+	key := fmt.Sprintf("%s:%s:%s", metadata.NetWork.String(), metadata.SrcIP.String(), metadata.SrcPort)
+	if cached, hit := processCache.Get(key); hit {
+		return cached.(string)
+	}
+
+	srcPort, err := strconv.Atoi(metadata.SrcPort)
+	if err != nil {
+		processCache.Set(key, "")
+		return ""
+	}
+
+	name, err := process.FindProcessName(metadata.NetWork.String(), metadata.SrcIP, srcPort)
+	if err != nil {
+		log.Debugln("[Rule] find process path error: %s", err.Error())
+	}
+
+	processCache.Set(key, name)
+	return name
+}
@@ -5,12 +5,51 @@ import (
 	"crypto/md5"
 	providerType "github.com/Dreamacro/clash/constant/provider"
 	"github.com/Dreamacro/clash/log"
+	"github.com/fsnotify/fsnotify"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// fileWatchDebounce is how long the watchLoop waits after the last event on
+// a watched file before re-pulling it, so a single save (which can fire
+// several WRITE/CREATE events) only triggers one Update.
+const fileWatchDebounce = 200 * time.Millisecond
+
+// retryBackoffMin/Max bound how fast pullLoop retries after onUpdate
+// rejects a freshly pulled payload (e.g. an invalid CIDR in an IPCIDR
+// ruleset) - an upstream publishing broken content shouldn't be re-parsed
+// every tick, but a transient bad pull should still recover faster than
+// waiting for the next full `interval:` tick.
+const (
+	retryBackoffMin = 5 * time.Second
+	retryBackoffMax = 5 * time.Minute
+)
+
+// retryBackoff doubles on every consecutive failure, up to retryBackoffMax,
+// and resets once a pull succeeds.
+type retryBackoff struct {
+	cur time.Duration
+}
+
+func (b *retryBackoff) next() time.Duration {
+	if b.cur == 0 {
+		b.cur = retryBackoffMin
+	} else if b.cur < retryBackoffMax {
+		b.cur *= 2
+		if b.cur > retryBackoffMax {
+			b.cur = retryBackoffMax
+		}
+	}
+	return b.cur
+}
+
+func (b *retryBackoff) reset() {
+	b.cur = 0
+}
+
 var (
 	fileMode os.FileMode = 0666
 	dirMode  os.FileMode = 0755
@@ -18,15 +57,79 @@ var (
 
 type parser = func([]byte) (interface{}, error)
 
+// conditionalVehicle is implemented by vehicles (e.g. the HTTP vehicle in
+// adapter/provider) that can perform a conditional fetch using a
+// previously seen ETag / Last-Modified pair, reporting notModified instead
+// of allocating a body when the upstream content hasn't changed.
+type conditionalVehicle interface {
+	providerType.Vehicle
+	ReadConditional(etag, lastModified string) (buf []byte, newETag, newLastModified string, notModified bool, err error)
+}
+
 type fetcher struct {
-	name      string
-	vehicle   providerType.Vehicle
-	updatedAt *time.Time
-	ticker    *time.Ticker
-	done      chan struct{}
-	hash      [16]byte
-	parser    parser
-	onUpdate  func(interface{}) error
+	name         string
+	vehicle      providerType.Vehicle
+	updatedAt    *time.Time
+	ticker       *time.Ticker
+	done         chan struct{}
+	hash         [16]byte
+	parser       parser
+	onUpdate     func(interface{}) error
+	interval     time.Duration
+	lastTouched  *time.Time
+	etag         string
+	lastModified string
+	watcher      *fsnotify.Watcher
+	lastError    error
+	pending      pendingUpdate
+}
+
+// pendingUpdate is what Update/update found on a successful pull, held back
+// from both the on-disk cache and f's own hash/etag/updatedAt bookkeeping
+// until the caller's onUpdate has accepted the parsed payload. Writing the
+// raw bytes to disk (or committing the new hash) before that point would
+// mean a payload onUpdate rejects - e.g. a ruleset that fails to compile
+// into a trie - still lands in the local cache file, so a process restart
+// right after a rejected pull would load the broken payload with nothing
+// to validate it against.
+type pendingUpdate struct {
+	buf          []byte
+	updatedAt    time.Time
+	hash         [16]byte
+	etag         string
+	lastModified string
+}
+
+// commit persists p.buf to the local cache file (unless the vehicle is
+// itself that file) and advances f's hash/etag/updatedAt bookkeeping to p.
+// Only called once the caller's onUpdate has returned nil for p.
+func (f *fetcher) commit(p pendingUpdate) error {
+	if f.vehicle.Type() != providerType.File {
+		if err := safeWrite(f.vehicle.Path(), p.buf); err != nil {
+			return err
+		}
+	}
+	writeETagCache(f.vehicle.Path(), p.etag, p.lastModified)
+
+	updatedAt := p.updatedAt
+	f.updatedAt = &updatedAt
+	f.hash = p.hash
+	f.etag, f.lastModified = p.etag, p.lastModified
+	return nil
+}
+
+// LastError is the error from the most recent failed pull or onUpdate, or
+// nil if the last attempt (or the only attempt so far) succeeded.
+func (f *fetcher) LastError() error {
+	return f.lastError
+}
+
+// Touch marks the provider as in-use, so the next ticks keep refreshing it.
+// Providers that nobody has searched since the last tick are left alone,
+// so a large `interval:` on an unused ruleset doesn't cost a pull.
+func (f *fetcher) Touch() {
+	now := time.Now()
+	f.lastTouched = &now
 }
 
 func (f *fetcher) Name() string {
@@ -37,20 +140,27 @@ func (f *fetcher) VehicleType() providerType.VehicleType {
 	return f.vehicle.Type()
 }
 
+// Initial loads the first payload - from the local cache file if one exists
+// and parses, otherwise from the vehicle - and returns it without writing
+// anything to disk yet: like Update, it leaves that to the caller's commit
+// once onUpdate has accepted the result, so a semantically-bad first pull
+// (valid YAML, invalid CIDR) can't get cached to disk and brick every
+// future restart against a remote that's since been fixed.
 func (f *fetcher) Initial() (interface{}, error) {
 	var (
-		buf      []byte
-		hasLocal bool
-		err      error
+		buf       []byte
+		hasLocal  bool
+		updatedAt time.Time
+		err       error
 	)
 
 	if stat, fErr := os.Stat(f.vehicle.Path()); fErr == nil {
 		buf, err = ioutil.ReadFile(f.vehicle.Path())
-		modTime := stat.ModTime()
-		f.updatedAt = &modTime
+		updatedAt = stat.ModTime()
 		hasLocal = true
 	} else {
 		buf, err = f.vehicle.Read()
+		updatedAt = time.Now()
 	}
 
 	if err != nil {
@@ -74,23 +184,71 @@ func (f *fetcher) Initial() (interface{}, error) {
 		}
 
 		hasLocal = false
+		updatedAt = time.Now()
 	}
 
-	if f.vehicle.Type() != providerType.File && !hasLocal {
-		if err := safeWrite(f.vehicle.Path(), buf); err != nil {
-			return nil, err
-		}
+	var etag, lastModified string
+	if hasLocal {
+		etag, lastModified = readETagCache(f.vehicle.Path())
+	}
+
+	f.pending = pendingUpdate{
+		buf:          buf,
+		updatedAt:    updatedAt,
+		hash:         md5.Sum(buf),
+		etag:         etag,
+		lastModified: lastModified,
 	}
 
-	f.hash = md5.Sum(buf)
 	if f.ticker != nil {
 		go f.pullLoop()
 	}
+	if f.watcher != nil {
+		go f.watchLoop()
+	}
 
 	return rules, nil
 }
 
+// Update pulls the latest payload and, if it parses into something new,
+// parses it and returns the result without yet writing it anywhere: f.pending
+// holds the raw bytes and cache-control state for the caller to persist via
+// commit once onUpdate has accepted the parsed result.
 func (f *fetcher) Update() (interface{}, bool, error) {
+	cv, ok := f.vehicle.(conditionalVehicle)
+	if !ok {
+		return f.update()
+	}
+
+	buf, etag, lastModified, notModified, err := cv.ReadConditional(f.etag, f.lastModified)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	if notModified {
+		log.Debugln("[Provider] %s not modified", f.Name())
+		f.updatedAt = &now
+		return nil, true, nil
+	}
+
+	rules, err := f.parser(buf)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f.pending = pendingUpdate{
+		buf:          buf,
+		updatedAt:    now,
+		hash:         md5.Sum(buf),
+		etag:         etag,
+		lastModified: lastModified,
+	}
+
+	return rules, false, nil
+}
+
+func (f *fetcher) update() (interface{}, bool, error) {
 	buf, err := f.vehicle.Read()
 	if err != nil {
 		return nil, false, err
@@ -108,15 +266,12 @@ func (f *fetcher) Update() (interface{}, bool, error) {
 		return nil, false, err
 	}
 
-	if f.vehicle.Type() != providerType.File {
-		if err := safeWrite(f.vehicle.Path(), buf); err != nil {
-			return nil, false, err
-		}
+	f.pending = pendingUpdate{
+		buf:       buf,
+		updatedAt: now,
+		hash:      hash,
 	}
 
-	f.updatedAt = &now
-	f.hash = hash
-
 	return rules, false, nil
 }
 
@@ -124,6 +279,9 @@ func (f *fetcher) Destroy() error {
 	if f.ticker != nil {
 		f.done <- struct{}{}
 	}
+	if f.watcher != nil {
+		return f.watcher.Close()
+	}
 	return nil
 }
 
@@ -133,14 +291,36 @@ func newFetcher(name string, interval time.Duration, vehicle providerType.Vehicl
 		ticker = time.NewTicker(interval)
 	}
 
-	return &fetcher{
+	f := &fetcher{
 		name:     name,
 		ticker:   ticker,
 		vehicle:  vehicle,
 		parser:   parser,
 		done:     make(chan struct{}, 1),
 		onUpdate: onUpdate,
+		interval: interval,
 	}
+
+	if vehicle.Type() == providerType.File {
+		if watcher, err := fsnotify.NewWatcher(); err == nil {
+			if err := watcher.Add(filepath.Dir(vehicle.Path())); err == nil {
+				f.watcher = watcher
+			} else {
+				watcher.Close()
+			}
+		}
+	}
+
+	return f
+}
+
+// shouldPull reports whether the provider has been touched recently enough
+// to be worth re-pulling on this tick.
+func (f *fetcher) shouldPull() bool {
+	if f.lastTouched == nil {
+		return false
+	}
+	return time.Since(*f.lastTouched) < f.interval
 }
 
 func safeWrite(path string, buf []byte) error {
@@ -155,32 +335,160 @@ func safeWrite(path string, buf []byte) error {
 	return ioutil.WriteFile(path, buf, fileMode)
 }
 
+// etagCachePath returns the sidecar file a ruleset's ETag/Last-Modified
+// pair is persisted to, so a restart doesn't lose conditional-fetch state.
+func etagCachePath(path string) string {
+	return path + ".etag"
+}
+
+func readETagCache(path string) (etag, lastModified string) {
+	buf, err := ioutil.ReadFile(etagCachePath(path))
+	if err != nil {
+		return "", ""
+	}
+
+	parts := strings.SplitN(string(buf), "\n", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+func writeETagCache(path, etag, lastModified string) {
+	if etag == "" && lastModified == "" {
+		return
+	}
+	_ = safeWrite(etagCachePath(path), []byte(etag+"\n"+lastModified))
+}
+
 func (f *fetcher) pullLoop() {
+	first := true
+	backoff := &retryBackoff{}
+	var retry <-chan time.Time
+
+	pull := func() {
+		elm, same, err := f.Update()
+		if err != nil {
+			f.lastError = err
+			log.Warnln("[Provider] %s pull error: %s", f.Name(), err.Error())
+			return
+		}
+
+		if same {
+			log.Debugln("[Provider] %s's rules doesn't change", f.Name())
+			backoff.reset()
+			return
+		}
+
+		if f.onUpdate != nil {
+			if err := f.onUpdate(elm); err != nil {
+				f.lastError = err
+				wait := backoff.next()
+				log.Warnln("[Provider] %s update rejected, keeping previous rules and retrying in %s: %s", f.Name(), wait, err.Error())
+				retry = time.After(wait)
+				return
+			}
+		}
+
+		if err := f.commit(f.pending); err != nil {
+			f.lastError = err
+			log.Warnln("[Provider] %s failed to persist update: %s", f.Name(), err.Error())
+			return
+		}
+
+		log.Infoln("[Provider] %s's rules update", f.Name())
+		f.lastError = nil
+		backoff.reset()
+	}
+
 	for {
 		select {
 		case <-f.ticker.C:
+			if !first && !f.shouldPull() {
+				log.Debugln("[Provider] %s hasn't been used since the last pull, skip", f.Name())
+				continue
+			}
+			first = false
+			pull()
+
+		case <-retry:
+			retry = nil
+			pull()
+
+		case <-f.done:
+			f.ticker.Stop()
+			return
+		}
+	}
+}
+
+// watchLoop re-pulls a File-vehicle provider as soon as its backing file
+// changes on disk, instead of waiting for the next ticker pull (or never,
+// if the provider has no `interval:` at all). The file's directory is
+// watched rather than the file itself, since an external updater replacing
+// the file (e.g. `mv tmp path`) swaps the inode a direct file-watch would
+// be watching. Bursts of events from a single save are debounced so one
+// edit triggers one Update.
+func (f *fetcher) watchLoop() {
+	var debounce *time.Timer
+	trigger := make(chan struct{}, 1)
+
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) != filepath.Clean(f.vehicle.Path()) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			if debounce == nil {
+				debounce = time.AfterFunc(fileWatchDebounce, func() {
+					trigger <- struct{}{}
+				})
+			} else {
+				debounce.Reset(fileWatchDebounce)
+			}
+
+		case <-trigger:
 			elm, same, err := f.Update()
 			if err != nil {
-				log.Warnln("[Provider] %s pull error: %s", f.Name(), err.Error())
+				f.lastError = err
+				log.Warnln("[Provider] %s reload error: %s", f.Name(), err.Error())
 				continue
 			}
 
 			if same {
-				log.Debugln("[Provider] %s's rules doesn't change", f.Name())
 				continue
 			}
 
-			log.Infoln("[Provider] %s's rules update", f.Name())
 			if f.onUpdate != nil {
-				err := f.onUpdate(elm)
-				if err != nil {
-					log.Infoln("[Provider] %s update failed", f.Name())
+				if err := f.onUpdate(elm); err != nil {
+					f.lastError = err
+					log.Warnln("[Provider] %s update rejected, keeping previous rules: %s", f.Name(), err.Error())
+					continue
 				}
 			}
 
-		case <-f.done:
-			f.ticker.Stop()
-			return
+			if err := f.commit(f.pending); err != nil {
+				f.lastError = err
+				log.Warnln("[Provider] %s failed to persist update: %s", f.Name(), err.Error())
+				continue
+			}
+
+			log.Infoln("[Provider] %s's rules update", f.Name())
+			f.lastError = nil
+
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnln("[Provider] %s watch error: %s", f.Name(), err.Error())
 		}
 	}
 }
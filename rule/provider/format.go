@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Format is the on-disk encoding of a rule provider's payload, orthogonal
+// to Behavior (which says how the resulting rules are matched).
+type Format int
+
+const (
+	YAML Format = iota
+	Text
+	Hosts
+	MMDB
+)
+
+func (f Format) String() string {
+	switch f {
+	case YAML:
+		return "YAML"
+	case Text:
+		return "Text"
+	case Hosts:
+		return "Hosts"
+	case MMDB:
+		return "MMDB"
+	default:
+		return ""
+	}
+}
+
+// parserForFormat returns the parser used to turn a provider's raw payload
+// into the []string rules constructRules expects, regardless of Behavior.
+func parserForFormat(format Format) (parser, error) {
+	switch format {
+	case YAML:
+		return rulesParse, nil
+	case Text:
+		return textParse, nil
+	case Hosts:
+		return hostsParse, nil
+	case MMDB:
+		return mmdbParse, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %d", format)
+	}
+}
+
+// textParse reads one rule per line from a plain line-delimited file,
+// e.g. a firehol-style IP list, skipping blank lines and `#` comments.
+func textParse(buf []byte) (interface{}, error) {
+	var rules []string
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	return rules, scanner.Err()
+}
+
+// hostsParse reads a hosts(5) file, e.g. StevenBlack/hosts, and turns every
+// domain on an entry line into a DOMAIN rule. Lines are `IP DOMAIN...`,
+// with `#` starting a trailing comment.
+func hostsParse(buf []byte) (interface{}, error) {
+	var rules []string
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx != -1 {
+			line = line[:idx]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		rules = append(rules, fields[1:]...)
+	}
+	return rules, scanner.Err()
+}
+
+// mmdbParse enumerates every CIDR in a MaxMind mmdb database, for use with
+// `behavior: ipcidr` providers such as country-block databases.
+func mmdbParse(buf []byte) (interface{}, error) {
+	db, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var rules []string
+	networks := db.Networks()
+	var raw struct{}
+	for networks.Next() {
+		network, err := networks.Network(&raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, network.String())
+	}
+	if err := networks.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
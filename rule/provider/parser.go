@@ -9,11 +9,14 @@ import (
 )
 
 type ruleProviderSchema struct {
-	Type     string `provider:"type"`
-	Behavior string `provider:"behavior"`
-	Path     string `provider:"path"`
-	URL      string `provider:"url,omitempty"`
-	Interval int    `provider:"interval,omitempty"`
+	Type          string `provider:"type"`
+	Behavior      string `provider:"behavior"`
+	Format        string `provider:"format,omitempty"`
+	Path          string `provider:"path"`
+	URL           string `provider:"url,omitempty"`
+	Interval      int    `provider:"interval,omitempty"`
+	Filter        string `provider:"filter,omitempty"`
+	ExcludeFilter string `provider:"exclude-filter,omitempty"`
 }
 
 func ParseRuleProvider(name string, mapping map[string]interface{}) (RuleProvider, error) {
@@ -35,6 +38,20 @@ func ParseRuleProvider(name string, mapping map[string]interface{}) (RuleProvide
 		return nil, fmt.Errorf("unsupported behavior type: %s", schema.Behavior)
 	}
 
+	var format Format
+	switch schema.Format {
+	case "", "yaml":
+		format = YAML
+	case "text":
+		format = Text
+	case "hosts":
+		format = Hosts
+	case "mmdb":
+		format = MMDB
+	default:
+		return nil, fmt.Errorf("unsupported format type: %s", schema.Format)
+	}
+
 	path := C.Path.Resolve(schema.Path)
 	var vehicle provider.Vehicle
 	switch schema.Type {
@@ -46,5 +63,6 @@ func ParseRuleProvider(name string, mapping map[string]interface{}) (RuleProvide
 		return nil, fmt.Errorf("unsupported vehicle type: %s", schema.Type)
 	}
 
-	return NewRuleSetProvider(name, behavior, time.Duration(uint(schema.Interval))*time.Second, vehicle), nil
+	return NewRuleSetProvider(name, behavior, format, time.Duration(uint(schema.Interval))*time.Second, vehicle,
+		WithFilter(schema.Filter), WithExcludeFilter(schema.ExcludeFilter))
 }
@@ -3,11 +3,14 @@ package provider
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/Dreamacro/clash/adapter/provider"
 	"github.com/Dreamacro/clash/component/trie"
 	C "github.com/Dreamacro/clash/constant"
 	R "github.com/Dreamacro/clash/rule"
 	"gopkg.in/yaml.v2"
+	"net"
+	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -36,19 +39,70 @@ func (b Behavior) String() string {
 
 type RuleProvider interface {
 	provider.Provider
+	// Search touches the provider (marking it in-use for the fetcher's
+	// pullLoop) and reports whether metadata matches a rule.
 	Search(metadata *C.Metadata) bool
+	// SearchNoTouch behaves like Search but doesn't keep the provider
+	// "alive", so inspecting a provider (e.g. the RESTful API) doesn't
+	// by itself defeat lazy refresh.
+	SearchNoTouch(metadata *C.Metadata) bool
 	RuleCount() int
 	Behavior() Behavior
 }
 type ruleSetProvider struct {
 	*fetcher
 	behavior       Behavior
+	format         Format
 	count          int
+	filter         *regexp.Regexp
+	excludeFilter  *regexp.Regexp
 	DomainRules    *trie.DomainTrie
 	IPCIDRRules    *trie.IpCidrTrie
 	ClassicalRules []C.Rule
 }
 
+// RuleSetOption customizes a RuleSetProvider at construction time.
+type RuleSetOption func(*ruleSetProvider)
+
+// WithFilter keeps only the raw rules matching the given regexp, letting
+// several rule-providers share one upstream ruleset by slicing it differently.
+func WithFilter(filter string) RuleSetOption {
+	return func(rp *ruleSetProvider) {
+		if filter == "" {
+			return
+		}
+		rp.filter = regexp.MustCompile(filter)
+	}
+}
+
+// WithExcludeFilter drops raw rules matching the given regexp.
+func WithExcludeFilter(excludeFilter string) RuleSetOption {
+	return func(rp *ruleSetProvider) {
+		if excludeFilter == "" {
+			return
+		}
+		rp.excludeFilter = regexp.MustCompile(excludeFilter)
+	}
+}
+
+func (rp *ruleSetProvider) applyFilters(rules []string) []string {
+	if rp.filter == nil && rp.excludeFilter == nil {
+		return rules
+	}
+
+	filtered := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		if rp.filter != nil && !rp.filter.MatchString(rule) {
+			continue
+		}
+		if rp.excludeFilter != nil && rp.excludeFilter.MatchString(rule) {
+			continue
+		}
+		filtered = append(filtered, rule)
+	}
+	return filtered
+}
+
 type RuleSetProvider struct {
 	*ruleSetProvider
 }
@@ -61,12 +115,25 @@ type RulePayload struct {
 	Rules []string `yaml:"payload"`
 }
 
-func NewRuleSetProvider(name string, behavior Behavior, interval time.Duration, vehicle provider.Vehicle) RuleProvider {
+func NewRuleSetProvider(name string, behavior Behavior, format Format, interval time.Duration, vehicle provider.Vehicle, options ...RuleSetOption) (RuleProvider, error) {
+	if format == MMDB && behavior != IPCIDR {
+		return nil, fmt.Errorf("format mmdb is only supported with behavior ipcidr")
+	}
+
+	parse, err := parserForFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
 	rp := &ruleSetProvider{
 		behavior: behavior,
+		format:   format,
+	}
+	for _, option := range options {
+		option(rp)
 	}
 	onUpdate := func(elm interface{}) error {
-		rulesRaw := elm.([]string)
+		rulesRaw := rp.applyFilters(elm.([]string))
 		rp.count = len(rulesRaw)
 		rules, err := constructRules(rp.behavior, rulesRaw)
 		if err != nil {
@@ -76,13 +143,13 @@ func NewRuleSetProvider(name string, behavior Behavior, interval time.Duration,
 		return nil
 	}
 
-	fetcher := newFetcher(name, interval, vehicle, rulesParse, onUpdate)
+	fetcher := newFetcher(name, interval, vehicle, parse, onUpdate)
 	rp.fetcher = fetcher
 	wrapper := &RuleSetProvider{
 		rp,
 	}
 	runtime.SetFinalizer(wrapper, stopRuleSetProvider)
-	return wrapper
+	return wrapper, nil
 }
 func (rp *ruleSetProvider) Name() string {
 	return rp.name
@@ -92,11 +159,16 @@ func (rp *ruleSetProvider) RuleCount() int {
 	return rp.count
 }
 func (rp *ruleSetProvider) Search(metadata *C.Metadata) bool {
+	rp.fetcher.Touch()
+	return rp.SearchNoTouch(metadata)
+}
+
+func (rp *ruleSetProvider) SearchNoTouch(metadata *C.Metadata) bool {
 	switch rp.behavior {
 	case Domain:
 		return rp.DomainRules.Search(metadata.Host) != nil
 	case IPCIDR:
-		return rp.IPCIDRRules.IsContain(metadata.DstIP.String())
+		return rp.IPCIDRRules.IsContain(metadata.DstIP)
 	case Classical:
 		for _, rule := range rp.ClassicalRules {
 			if rule.Match(metadata) {
@@ -120,20 +192,49 @@ func (rp *ruleSetProvider) Type() provider.ProviderType {
 	return provider.Rule
 }
 
+// Initial loads and validates the first payload before anything is written
+// to disk: rp.fetcher.Initial only parses and stages it in fetcher.pending,
+// so if onUpdate rejects it (e.g. invalid CIDR) there's nothing on disk yet
+// for a later restart to load back up against a since-fixed remote.
 func (rp *ruleSetProvider) Initial() error {
 	elm, err := rp.fetcher.Initial()
 	if err != nil {
 		return err
 	}
-	return rp.fetcher.onUpdate(elm)
+	if err := rp.fetcher.onUpdate(elm); err != nil {
+		return err
+	}
+	return rp.fetcher.commit(rp.fetcher.pending)
 }
 
+// Update triggers an out-of-band pull (e.g. from the REST API), independent
+// of the fetcher's own ticker/watcher. Like the fetcher's own pullLoop, it
+// only writes the new payload to disk (and advances hash/updatedAt) once
+// onUpdate has accepted it, so a manual refresh can't clobber the
+// currently-serving ruleset - in memory or on disk - with one that failed
+// to apply.
 func (rp *ruleSetProvider) Update() error {
 	elm, same, err := rp.fetcher.Update()
-	if err == nil && !same {
-		return rp.fetcher.onUpdate(elm)
+	if err != nil {
+		rp.fetcher.lastError = err
+		return err
 	}
-	return err
+	if same {
+		return nil
+	}
+
+	if err := rp.fetcher.onUpdate(elm); err != nil {
+		rp.fetcher.lastError = err
+		return err
+	}
+
+	if err := rp.fetcher.commit(rp.fetcher.pending); err != nil {
+		rp.fetcher.lastError = err
+		return err
+	}
+
+	rp.fetcher.lastError = nil
+	return nil
 }
 func (rp *ruleSetProvider) setRules(rules interface{}) {
 	switch rp.behavior {
@@ -147,14 +248,31 @@ func (rp *ruleSetProvider) setRules(rules interface{}) {
 	}
 }
 func (rp ruleSetProvider) MarshalJSON() ([]byte, error) {
+	var filter, excludeFilter string
+	if rp.filter != nil {
+		filter = rp.filter.String()
+	}
+	if rp.excludeFilter != nil {
+		excludeFilter = rp.excludeFilter.String()
+	}
+
+	var lastError string
+	if rp.lastError != nil {
+		lastError = rp.lastError.Error()
+	}
+
 	return json.Marshal(
 		map[string]interface{}{
-			"behavior":    rp.behavior.String(),
-			"name":        rp.Name(),
-			"ruleCount":   rp.RuleCount(),
-			"type":        rp.Type().String(),
-			"updatedAt":   rp.updatedAt,
-			"vehicleType": rp.VehicleType().String(),
+			"behavior":      rp.behavior.String(),
+			"format":        rp.format.String(),
+			"name":          rp.Name(),
+			"ruleCount":     rp.RuleCount(),
+			"type":          rp.Type().String(),
+			"updatedAt":     rp.updatedAt,
+			"vehicleType":   rp.VehicleType().String(),
+			"filter":        filter,
+			"excludeFilter": excludeFilter,
+			"lastError":     lastError,
 		})
 }
 func rulesParse(buf []byte) (interface{}, error) {
@@ -198,7 +316,11 @@ func handleIpCidrRules(rules []string) (interface{}, error) {
 		if ruleType != "" {
 			return nil, errors.New("error format of ip-cidr")
 		}
-		if err := ipCidrRules.AddIpCidr(rule); err != nil {
+		_, ipNet, err := net.ParseCIDR(rule)
+		if err != nil {
+			return nil, err
+		}
+		if err := ipCidrRules.AddIpCidr(ipNet); err != nil {
 			return nil, err
 		}
 	}
@@ -17,6 +17,10 @@ func (rs *RuleSet) RuleType() C.RuleType {
 	return C.RuleSet
 }
 
+// Match is safe to call from within a SUB-RULE subtree: it only reads
+// metadata and the (lazily-cached, otherwise immutable) rule provider, so
+// it carries no state across calls that recursion into a sub-rules group
+// could corrupt or loop on.
 func (rs *RuleSet) Match(metadata *C.Metadata) bool {
 	return rs.getProviders().Search(metadata)
 }
@@ -0,0 +1,41 @@
+package rules
+
+import C "github.com/Dreamacro/clash/constant"
+
+// SubRule is SUB-RULE(name)'s entry in a rule group: the name of another
+// subRules group to evaluate in its place. tunnel.match special-cases
+// C.SubRule when walking a group, recursing into subRules[name] itself
+// (with a visited-set guard against a group that refers back to itself)
+// rather than ever calling Match - Match is only here to satisfy C.Rule
+// for anything that ranges over a rule group without that special case.
+type SubRule struct {
+	ruleSetName string
+}
+
+func (sr *SubRule) RuleType() C.RuleType {
+	return C.SubRule
+}
+
+func (sr *SubRule) Match(metadata *C.Metadata) bool {
+	return false
+}
+
+func (sr *SubRule) Adapter() string {
+	return ""
+}
+
+func (sr *SubRule) Payload() string {
+	return sr.ruleSetName
+}
+
+func (sr *SubRule) ShouldResolveIP() bool {
+	return false
+}
+
+func NewSubRule(ruleSetName string) (*SubRule, error) {
+	if ruleSetName == "" {
+		return nil, errPayload
+	}
+
+	return &SubRule{ruleSetName: ruleSetName}, nil
+}
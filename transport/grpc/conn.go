@@ -0,0 +1,197 @@
+package grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// pipeBody is the streaming request body: Write() feeds outgoing frames,
+// while http2.Transport reads from it as the RPC request stream.
+type pipeBody struct {
+	*io.PipeReader
+	w *io.PipeWriter
+}
+
+func newPipeBody() *pipeBody {
+	r, w := io.Pipe()
+	return &pipeBody{PipeReader: r, w: w}
+}
+
+func (b *pipeBody) Close() error {
+	_ = b.w.Close()
+	return b.PipeReader.Close()
+}
+
+// conn adapts a single gRPC bidirectional-streaming "Tun" call to net.Conn,
+// framing each Write as one Hunk{ data bytes } message the same way a
+// generated gRPC client stub would.
+type conn struct {
+	remoteAddr net.Addr
+	body       *pipeBody
+	resp       *http.Response
+	respErr    chan error
+
+	readBuf []byte
+}
+
+func newGRPCConn(transport *http2.Transport, url string, remoteAddr net.Addr) (net.Conn, error) {
+	body := newPipeBody()
+	req, err := newRequest(context.Background(), url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{
+		remoteAddr: remoteAddr,
+		body:       body,
+		respErr:    make(chan error, 1),
+	}
+
+	go func() {
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			c.respErr <- err
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			c.respErr <- errors.New("grpc: unexpected status " + resp.Status)
+			return
+		}
+		c.resp = resp
+		c.respErr <- nil
+	}()
+
+	if err := <-c.respErr; err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	for len(c.readBuf) == 0 {
+		data, err := readHunk(c.resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		c.readBuf = data
+	}
+
+	n := copy(b, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+	return n, nil
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	if err := writeHunk(c.body.w, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *conn) Close() error {
+	return c.body.Close()
+}
+
+func (c *conn) LocalAddr() net.Addr { return nil }
+
+func (c *conn) RemoteAddr() net.Addr { return c.remoteAddr }
+
+func (c *conn) SetDeadline(t time.Time) error { return nil }
+
+func (c *conn) SetReadDeadline(t time.Time) error { return nil }
+
+func (c *conn) SetWriteDeadline(t time.Time) error { return nil }
+
+// writeHunk encodes b as a single gRPC message carrying a protobuf
+// Hunk{ bytes data = 1 } value, then writes the 5-byte gRPC frame header
+// (1 compression byte + 4 byte big-endian length) in front of it.
+func writeHunk(w io.Writer, b []byte) error {
+	msg := encodeHunk(b)
+
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(msg)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// readHunk reads one gRPC-framed message and decodes it back into the raw
+// Hunk.data bytes.
+func readHunk(r io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+
+	return decodeHunk(msg)
+}
+
+// encodeHunk produces the protobuf wire encoding of message Hunk { bytes
+// data = 1; }: a single length-delimited field 1.
+func encodeHunk(data []byte) []byte {
+	out := make([]byte, 0, len(data)+5)
+	out = append(out, 0x0A) // field 1, wire type 2 (length-delimited)
+	out = appendVarint(out, uint64(len(data)))
+	out = append(out, data...)
+	return out
+}
+
+func decodeHunk(msg []byte) ([]byte, error) {
+	if len(msg) == 0 {
+		return nil, nil
+	}
+	if msg[0] != 0x0A {
+		return nil, errors.New("grpc: unexpected Hunk field tag")
+	}
+
+	length, n := readVarint(msg[1:])
+	if n == 0 {
+		return nil, errors.New("grpc: truncated Hunk length")
+	}
+
+	start := 1 + n
+	end := start + int(length)
+	if end > len(msg) {
+		return nil, errors.New("grpc: truncated Hunk payload")
+	}
+
+	return msg[start:end], nil
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func readVarint(buf []byte) (uint64, int) {
+	var v uint64
+	var shift uint
+	for i, b := range buf {
+		v |= uint64(b&0x7F) << shift
+		if b < 0x80 {
+			return v, i + 1
+		}
+		shift += 7
+	}
+	return 0, 0
+}
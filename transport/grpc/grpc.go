@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// GRPCConfig configures a single "Tun" bidirectional-streaming gRPC call
+// used as a CDN-friendly alternative to WebSocket.
+type GRPCConfig struct {
+	Host           string
+	Port           string
+	ServiceName    string
+	TLS            bool
+	SkipCertVerify bool
+	ServerName     string
+	SessionCache   tls.ClientSessionCache
+}
+
+// StreamGRPCConn dials a single `Tun` stream on top of conn and returns a
+// net.Conn whose Read/Write speak the gRPC length-prefixed framing, mirroring
+// vmess.StreamWebsocketConn.
+func StreamGRPCConn(conn net.Conn, cfg *GRPCConfig) (net.Conn, error) {
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "GunService"
+	}
+
+	scheme := "http"
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, tlsCfg *tls.Config) (net.Conn, error) {
+			return conn, nil
+		},
+	}
+
+	if cfg.TLS {
+		scheme = "https"
+		tlsConfig := &tls.Config{
+			ServerName:         cfg.Host,
+			InsecureSkipVerify: cfg.SkipCertVerify,
+			ClientSessionCache: cfg.SessionCache,
+			NextProtos:         []string{"h2"},
+		}
+		if cfg.ServerName != "" {
+			tlsConfig.ServerName = cfg.ServerName
+		}
+
+		transport.AllowHTTP = false
+		transport.DialTLS = func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+
+	url := fmt.Sprintf("%s://%s/%s/Tun", scheme, net.JoinHostPort(cfg.Host, cfg.Port), serviceName)
+	return newGRPCConn(transport, url, conn.RemoteAddr())
+}
+
+func newRequest(ctx context.Context, url string, body *pipeBody) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+	req.ContentLength = -1
+	return req, nil
+}
@@ -0,0 +1,207 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Dreamacro/clash/common/structure"
+)
+
+func init() {
+	Register("obfs", newObfs)
+}
+
+type obfsSchema struct {
+	Mode string `obfs:"mode,omitempty"`
+	Host string `obfs:"host,omitempty"`
+}
+
+func newObfs(conn net.Conn, opts map[string]interface{}) (net.Conn, error) {
+	schema := &obfsSchema{Mode: "http", Host: "bing.com"}
+	decoder := structure.NewDecoder(structure.Option{TagName: "obfs", WeaklyTypedInput: true})
+	if err := decoder.Decode(opts, schema); err != nil {
+		return nil, err
+	}
+
+	switch schema.Mode {
+	case "http":
+		return newHTTPObfsConn(conn, schema.Host), nil
+	case "tls":
+		return newTLSObfsConn(conn, schema.Host), nil
+	default:
+		return nil, fmt.Errorf("obfs: unsupported mode %s", schema.Mode)
+	}
+}
+
+// httpObfsConn disguises the first outbound write as an HTTP/1.1 GET
+// request and strips the peer's HTTP response header off the first read,
+// the same camouflage shadowsocks' simple-obfs http mode uses.
+type httpObfsConn struct {
+	net.Conn
+	host       string
+	mux        sync.Mutex
+	firstWrite bool
+	firstRead  bool
+}
+
+func newHTTPObfsConn(conn net.Conn, host string) *httpObfsConn {
+	return &httpObfsConn{Conn: conn, host: host, firstWrite: true, firstRead: true}
+}
+
+func (c *httpObfsConn) Read(b []byte) (int, error) {
+	if !c.firstRead {
+		return c.Conn.Read(b)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(c.Conn), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	c.firstRead = false
+	return resp.Body.Read(b)
+}
+
+func (c *httpObfsConn) Write(b []byte) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if !c.firstWrite {
+		return c.Conn.Write(b)
+	}
+	c.firstWrite = false
+
+	randBytes := make([]byte, 16)
+	rand.Read(randBytes)
+
+	req := fmt.Sprintf(
+		"GET / HTTP/1.1\r\nHost: %s\r\nUser-Agent: curl/7.64.1\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nContent-Length: %d\r\n\r\n",
+		c.host, base64.StdEncoding.EncodeToString(randBytes), len(b),
+	)
+
+	if _, err := c.Conn.Write([]byte(req)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// tlsObfsConn wraps each write in a minimal fake TLS application-data
+// record header and strips the peer's fake record header off each read,
+// the same camouflage shadowsocks' simple-obfs tls mode uses. It only
+// imitates the record framing, not a real TLS handshake.
+type tlsObfsConn struct {
+	net.Conn
+	host       string
+	mux        sync.Mutex
+	firstWrite bool
+	remain     int
+}
+
+func newTLSObfsConn(conn net.Conn, host string) *tlsObfsConn {
+	return &tlsObfsConn{Conn: conn, host: host, firstWrite: true}
+}
+
+func (c *tlsObfsConn) Read(b []byte) (int, error) {
+	if c.remain > 0 {
+		if c.remain < len(b) {
+			b = b[:c.remain]
+		}
+		n, err := c.Conn.Read(b)
+		c.remain -= n
+		return n, err
+	}
+
+	var header [5]byte
+	if _, err := io.ReadFull(c.Conn, header[:]); err != nil {
+		return 0, err
+	}
+	length := int(binary.BigEndian.Uint16(header[3:5]))
+	if length < len(b) {
+		b = b[:length]
+	}
+	n, err := c.Conn.Read(b)
+	c.remain = length - n
+	return n, err
+}
+
+func (c *tlsObfsConn) Write(b []byte) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.firstWrite {
+		c.firstWrite = false
+		if err := c.writeClientHello(); err != nil {
+			return 0, err
+		}
+	}
+
+	total := len(b)
+	buf := &bytes.Buffer{}
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > 0x3fff {
+			chunk = chunk[:0x3fff]
+		}
+		buf.Write([]byte{0x17, 0x03, 0x03, byte(len(chunk) >> 8), byte(len(chunk))})
+		buf.Write(chunk)
+		b = b[len(chunk):]
+	}
+	if _, err := c.Conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// writeClientHello sends a fixed-shape fake ClientHello record carrying
+// host as its SNI, just enough to look like a real TLS handshake to
+// passive inspection.
+func (c *tlsObfsConn) writeClientHello() error {
+	random := make([]byte, 32)
+	rand.Read(random)
+	sessionID := make([]byte, 32)
+	rand.Read(sessionID)
+
+	ext := &bytes.Buffer{}
+	ext.Write([]byte{0x00, 0x00}) // extension type: server_name
+	host := []byte(strings.TrimSuffix(c.host, "."))
+	snBody := &bytes.Buffer{}
+	snBody.WriteByte(0x00) // name type: host_name
+	snBody.Write([]byte{byte(len(host) >> 8), byte(len(host))})
+	snBody.Write(host)
+	snList := &bytes.Buffer{}
+	snList.Write([]byte{byte(snBody.Len() >> 8), byte(snBody.Len())})
+	snList.Write(snBody.Bytes())
+	ext.Write([]byte{byte(snList.Len() >> 8), byte(snList.Len())})
+	ext.Write(snList.Bytes())
+
+	hello := &bytes.Buffer{}
+	hello.Write([]byte{0x03, 0x03}) // client_version: TLS 1.2
+	hello.Write(random)
+	hello.WriteByte(byte(len(sessionID)))
+	hello.Write(sessionID)
+	hello.Write([]byte{0x00, 0x02, 0x13, 0x01}) // one cipher suite: TLS_AES_128_GCM_SHA256
+	hello.Write([]byte{0x01, 0x00})             // compression: null
+	hello.Write([]byte{byte(ext.Len() >> 8), byte(ext.Len())})
+	hello.Write(ext.Bytes())
+
+	handshake := &bytes.Buffer{}
+	handshake.WriteByte(0x01) // handshake type: client_hello
+	length := hello.Len()
+	handshake.Write([]byte{byte(length >> 16), byte(length >> 8), byte(length)})
+	handshake.Write(hello.Bytes())
+
+	record := &bytes.Buffer{}
+	record.Write([]byte{0x16, 0x03, 0x01})
+	record.Write([]byte{byte(handshake.Len() >> 8), byte(handshake.Len())})
+	record.Write(handshake.Bytes())
+
+	_, err := c.Conn.Write(record.Bytes())
+	return err
+}
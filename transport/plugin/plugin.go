@@ -0,0 +1,35 @@
+// Package plugin implements SIP003-style pluggable transports: net.Conn
+// wrappers, selected by name and configured by an opts map, that sit
+// between an outbound's raw dial and its protocol client. It is
+// transport-only — a Creator only ever sees the net.Conn and its own
+// opts, never proxy metadata — so the same registry backs Vless today and
+// can back Trojan/Vmess without change.
+package plugin
+
+import (
+	"fmt"
+	"net"
+)
+
+// Creator wraps conn per opts (the parsed plugin-opts map) and returns the
+// replacement net.Conn the caller should use from that point on.
+type Creator func(conn net.Conn, opts map[string]interface{}) (net.Conn, error)
+
+var creators = map[string]Creator{}
+
+// Register adds a plugin implementation under name. Called from each
+// built-in plugin's init().
+func Register(name string, creator Creator) {
+	creators[name] = creator
+}
+
+// StreamConn wraps conn with the plugin registered under name. An unknown
+// name is an error rather than a silent passthrough, so a typo in
+// plugin: doesn't quietly disable the intended camouflage.
+func StreamConn(name string, conn net.Conn, opts map[string]interface{}) (net.Conn, error) {
+	creator, ok := creators[name]
+	if !ok {
+		return nil, fmt.Errorf("plugin %s not found", name)
+	}
+	return creator(conn, opts)
+}
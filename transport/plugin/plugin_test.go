@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamConn_UnknownPlugin(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	_, err := StreamConn("does-not-exist", client, nil)
+	assert.Error(t, err)
+}
+
+func TestHTTPObfs_RoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	payload := []byte("hello")
+	serverDone := make(chan []byte, 1)
+	go func() {
+		// the obfs Write wraps the request header and the payload in two
+		// separate Conn.Write calls, so net.Pipe (unbuffered) needs two
+		// matching Read calls to drain both.
+		var got []byte
+		buf := make([]byte, 4096)
+		n, _ := server.Read(buf)
+		got = append(got, buf[:n]...)
+		n, _ = server.Read(buf)
+		got = append(got, buf[:n]...)
+		serverDone <- got
+
+		resp := "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nworld"
+		server.Write([]byte(resp))
+	}()
+
+	wrapped, err := StreamConn("obfs", client, map[string]interface{}{"mode": "http", "host": "example.com"})
+	assert.Nil(t, err)
+
+	_, err = wrapped.Write(payload)
+	assert.Nil(t, err)
+
+	got := <-serverDone
+	assert.Contains(t, string(got), "GET / HTTP/1.1")
+	assert.Contains(t, string(got), "hello")
+
+	buf := make([]byte, 5)
+	n, err := wrapped.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+}
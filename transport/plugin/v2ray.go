@@ -0,0 +1,47 @@
+package plugin
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/Dreamacro/clash/common/structure"
+	"github.com/Dreamacro/clash/transport/vmess"
+)
+
+func init() {
+	Register("v2ray-plugin", newV2rayPlugin)
+}
+
+// v2rayPluginSchema mirrors the v2ray-plugin options clash's config files
+// already use for shadowsocks, minus "mux", which this package doesn't
+// implement - a v2ray-plugin peer configured for mux will simply see a
+// single stream per connection instead of a multiplexed one.
+type v2rayPluginSchema struct {
+	Mode           string `obfs:"mode,omitempty"`
+	Host           string `obfs:"host,omitempty"`
+	Path           string `obfs:"path,omitempty"`
+	TLS            bool   `obfs:"tls,omitempty"`
+	SkipCertVerify bool   `obfs:"skip-cert-verify,omitempty"`
+}
+
+func newV2rayPlugin(conn net.Conn, opts map[string]interface{}) (net.Conn, error) {
+	schema := &v2rayPluginSchema{Mode: "websocket", Path: "/"}
+	decoder := structure.NewDecoder(structure.Option{TagName: "obfs", WeaklyTypedInput: true})
+	if err := decoder.Decode(opts, schema); err != nil {
+		return nil, err
+	}
+
+	wsConfig := &vmess.WebsocketConfig{
+		Host:    schema.Host,
+		Path:    schema.Path,
+		Headers: http.Header{},
+	}
+
+	if schema.TLS {
+		wsConfig.TLS = true
+		wsConfig.SkipCertVerify = schema.SkipCertVerify
+		wsConfig.ServerName = schema.Host
+	}
+
+	return vmess.StreamWebsocketConn(conn, wsConfig, nil)
+}
@@ -130,6 +130,12 @@ func (wsc *websocketConn) SetWriteDeadline(t time.Time) error {
 }
 
 func StreamWebsocketEDConn(conn net.Conn, c *WebsocketConfig) (net.Conn, error) {
+	switch conn.(type) {
+	case *websocketEDConn, *websocketConn:
+		// already wrapped, don't buffer/handshake twice
+		return conn, nil
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	conn = &websocketEDConn{
 		dialed:   make(chan bool, 1),
@@ -141,6 +147,21 @@ func StreamWebsocketEDConn(conn net.Conn, c *WebsocketConfig) (net.Conn, error)
 	return conn, nil
 }
 
+// UnwrapConn returns the net.Conn underlying a websocketEDConn or
+// websocketConn wrapper, or conn unchanged if it isn't one. Transports that
+// compose on top of a WS conn (e.g. a retry path rebuilding on failure)
+// should unwrap first instead of stacking another WS layer on top.
+func UnwrapConn(conn net.Conn) net.Conn {
+	switch c := conn.(type) {
+	case *websocketEDConn:
+		return c.realConn
+	case *websocketConn:
+		return c.conn.UnderlyingConn()
+	default:
+		return conn
+	}
+}
+
 func (wsedc *websocketEDConn) Close() error {
 	wsedc.closed = true
 	wsedc.cancel()
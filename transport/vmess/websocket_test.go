@@ -0,0 +1,50 @@
+package vmess
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestStreamWebsocketEDConn_NoDoubleHandshake(t *testing.T) {
+	var handshakes atomic.Int32
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handshakes.Inc()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		assert.Nil(t, err)
+		defer conn.Close()
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.Nil(t, err)
+
+	raw, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	assert.Nil(t, err)
+
+	config := &WebsocketConfig{Host: host, Port: port, Path: "/"}
+
+	wrapped, err := StreamWebsocketEDConn(raw, config)
+	assert.Nil(t, err)
+
+	// Wrapping an already-wrapped ED conn must be a no-op: same conn back,
+	// no extra buffering layer.
+	rewrapped, err := StreamWebsocketEDConn(wrapped, config)
+	assert.Nil(t, err)
+	assert.Same(t, wrapped, rewrapped)
+
+	_, err = rewrapped.Write([]byte("ping"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), handshakes.Load())
+	assert.Same(t, raw, UnwrapConn(rewrapped))
+
+	rewrapped.Close()
+}
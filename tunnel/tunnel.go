@@ -3,6 +3,7 @@ package tunnel
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	ruleProvider "github.com/Dreamacro/clash/rule/provider"
 	"net"
 	"runtime"
@@ -12,11 +13,15 @@ import (
 	"github.com/Dreamacro/clash/adapter/inbound"
 	"github.com/Dreamacro/clash/component/nat"
 	"github.com/Dreamacro/clash/component/resolver"
+	"github.com/Dreamacro/clash/component/sniffer"
 	C "github.com/Dreamacro/clash/constant"
 	"github.com/Dreamacro/clash/constant/provider"
 	icontext "github.com/Dreamacro/clash/context"
 	"github.com/Dreamacro/clash/log"
+	R "github.com/Dreamacro/clash/rule"
 	"github.com/Dreamacro/clash/tunnel/statistic"
+
+	"go.uber.org/atomic"
 )
 
 var (
@@ -24,16 +29,28 @@ var (
 	udpQueue      = make(chan *inbound.PacketAdapter, 200)
 	natTable      = nat.New()
 	rules         []C.Rule
+	subRules      map[string][]C.Rule
 	proxies       = make(map[string]C.Proxy)
 	providers     map[string]provider.ProxyProvider
 	ruleProviders map[string]*ruleProvider.RuleProvider
 	configMux     sync.RWMutex
 
+	// snifferDispatcher peeks connections whose Host is still empty for a
+	// TLS SNI or HTTP Host, when non-nil. It's nil until UpdateSniffer is
+	// called, so builds/tests that never configure sniffing pay no cost.
+	snifferDispatcher *sniffer.SnifferDispatcher
+
 	// Outbound Rule
 	mode = Rule
 
 	// default timeout for UDP session
 	udpTimeout = 60 * time.Second
+
+	// udpFallbackMatch controls whether a UDP dial failure falls back to
+	// the next matching rule instead of dropping the packet, and
+	// udpFallbackCount counts how many times that fallback has fired.
+	udpFallbackMatch = atomic.NewBool(false)
+	udpFallbackCount = atomic.NewUint64(0)
 )
 
 func init() {
@@ -55,10 +72,17 @@ func Rules() []C.Rule {
 	return rules
 }
 
+// SubRules return all named sub-rules groups, keyed by the name a
+// listener's PreferRulesName refers to.
+func SubRules() map[string][]C.Rule {
+	return subRules
+}
+
 // UpdateRules handle update rules
-func UpdateRules(newRules []C.Rule, rp map[string]*ruleProvider.RuleProvider) {
+func UpdateRules(newRules []C.Rule, newSubRules map[string][]C.Rule, rp map[string]*ruleProvider.RuleProvider) {
 	configMux.Lock()
 	rules = newRules
+	subRules = newSubRules
 	ruleProviders = rp
 	configMux.Unlock()
 }
@@ -78,6 +102,15 @@ func RuleProviders() map[string]*ruleProvider.RuleProvider {
 	return ruleProviders
 }
 
+// UpdateSniffer swaps in a new sniffer.SnifferDispatcher, e.g. after a
+// config reload changes the sniffing: block's ports/force/skip lists.
+// Passing nil turns sniffing off entirely.
+func UpdateSniffer(dispatcher *sniffer.SnifferDispatcher) {
+	configMux.Lock()
+	snifferDispatcher = dispatcher
+	configMux.Unlock()
+}
+
 // UpdateProxies handle update proxies
 func UpdateProxies(newProxies map[string]C.Proxy, newProviders map[string]provider.ProxyProvider) {
 	configMux.Lock()
@@ -96,6 +129,35 @@ func SetMode(m TunnelMode) {
 	mode = m
 }
 
+// FindProcessMode returns the current find-process-mode, controlling how
+// eagerly a connection's owning process is resolved for PROCESS-NAME/
+// PROCESS-PATH rules (see rules.SetFindProcessMode).
+func FindProcessMode() R.FindProcessMode {
+	return R.GetFindProcessMode()
+}
+
+// SetFindProcessMode changes the find-process-mode.
+func SetFindProcessMode(m R.FindProcessMode) {
+	R.SetFindProcessMode(m)
+}
+
+// UDPFallbackMatch reports whether a UDP dial failure falls back to the
+// next matching rule instead of dropping the packet.
+func UDPFallbackMatch() bool {
+	return udpFallbackMatch.Load()
+}
+
+// SetUDPFallbackMatch turns UDP fallback matching on/off.
+func SetUDPFallbackMatch(on bool) {
+	udpFallbackMatch.Store(on)
+}
+
+// UDPFallbackCount is how many times UDP fallback matching has picked a
+// different proxy after the first one failed to dial.
+func UDPFallbackCount() uint64 {
+	return udpFallbackCount.Load()
+}
+
 // processUDP starts a loop to handle udp packet
 func processUDP() {
 	queue := udpQueue
@@ -168,6 +230,114 @@ func resolveMetadata(ctx C.PlainContext, metadata *C.Metadata) (proxy C.Proxy, r
 	return
 }
 
+// udpDialBackoff bounds NAT-table lock contention: callers waiting on an
+// in-flight dial for the same key back off between rechecks (10ms up to
+// 1s, doubling each time) instead of only relying on the lock's cond
+// broadcast, and jitter keeps a burst of packets for the same key from
+// all waking and rechecking at the same instant.
+type udpDialBackoff struct {
+	cur time.Duration
+}
+
+const (
+	udpDialBackoffMin    = 10 * time.Millisecond
+	udpDialBackoffMax    = time.Second
+	udpDialBackoffFactor = 2
+	udpDialMaxWaits      = 8
+)
+
+func (b *udpDialBackoff) next() time.Duration {
+	if b.cur == 0 {
+		b.cur = udpDialBackoffMin
+	}
+	wait := b.cur + time.Duration(rand.Int63n(int64(b.cur)))
+	b.cur = time.Duration(float64(b.cur) * udpDialBackoffFactor)
+	if b.cur > udpDialBackoffMax {
+		b.cur = udpDialBackoffMax
+	}
+	return wait
+}
+
+// waitForNAT waits for key's NAT entry to appear, rechecking the table
+// after each cond broadcast or backoff timeout (see udpDialBackoff),
+// whichever comes first, rather than relying solely on the broadcast - a
+// safety net in case it's ever missed, and a way to avoid every waiter
+// rechecking the table at the same instant. A broadcast with no entry yet
+// does not end the wait; it returns false after udpDialMaxWaits rechecks
+// that all found no entry.
+func waitForNAT(key string, cond *sync.Cond) bool {
+	b := &udpDialBackoff{}
+	for i := 0; i < udpDialMaxWaits; i++ {
+		woken := make(chan struct{})
+		go func() {
+			cond.L.Lock()
+			cond.Wait()
+			cond.L.Unlock()
+			close(woken)
+		}()
+
+		select {
+		case <-woken:
+		case <-time.After(b.next()):
+		}
+
+		if natTable.Get(key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// dialUDP resolves metadata to a proxy/rule and dials it. If the dial
+// fails and UDPFallbackMatch is enabled, it keeps walking the rest of the
+// matching rule list for another adapter to try instead of giving up on
+// the packet - there's no "rest of the list" to fall back to outside
+// Rule mode, so fallback only applies when resolveMetadata actually
+// matched a rule.
+func dialUDP(metadata *C.Metadata) (C.Proxy, C.Rule, C.PacketConn, error) {
+	pCtx := icontext.NewPacketConnContext(metadata)
+	proxy, rule, err := resolveMetadata(pCtx, metadata)
+	if err != nil {
+		log.Warnln("[UDP] Parse metadata failed: %s", err.Error())
+		return nil, nil, nil, err
+	}
+
+	var tried map[string]bool
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), C.DefaultUDPTimeout)
+		rawPc, dialErr := proxy.ListenPacketContext(ctx, metadata)
+		cancel()
+		if dialErr == nil {
+			pCtx.InjectPacketConn(rawPc)
+			return proxy, rule, rawPc, nil
+		}
+
+		if rule == nil {
+			log.Warnln("[UDP] dial %s to %s error: %s", proxy.Name(), metadata.RemoteAddress(), dialErr.Error())
+		} else {
+			log.Warnln("[UDP] dial %s (match %s/%s) to %s error: %s", proxy.Name(), rule.RuleType().String(), rule.Payload(), metadata.RemoteAddress(), dialErr.Error())
+		}
+
+		if !UDPFallbackMatch() || rule == nil {
+			return nil, nil, nil, dialErr
+		}
+
+		if tried == nil {
+			tried = map[string]bool{}
+		}
+		tried[proxy.Name()] = true
+
+		next, nextRule, matchErr := matchExcluding(metadata, tried)
+		if matchErr != nil || tried[next.Name()] {
+			return nil, nil, nil, dialErr
+		}
+
+		log.Infoln("[UDP] fallback %s -> %s", proxy.Name(), next.Name())
+		udpFallbackCount.Inc()
+		proxy, rule = next, nextRule
+	}
+}
+
 func handleUDPConn(packet *inbound.PacketAdapter) {
 	metadata := packet.Metadata()
 	if !metadata.Valid() {
@@ -186,6 +356,14 @@ func handleUDPConn(packet *inbound.PacketAdapter) {
 		return
 	}
 
+	if snifferDispatcher != nil {
+		// UDP packets arrive whole, so there's no stream to peek-and-replay
+		// the way TCPSniff does - the datagram's bytes are sniffed in
+		// place and metadata is rewritten before the rest of this function
+		// ever touches packet.Data().
+		snifferDispatcher.UDPSniff(packet.Data(), metadata)
+	}
+
 	key := packet.LocalAddr().String()
 
 	handle := func() bool {
@@ -206,10 +384,9 @@ func handleUDPConn(packet *inbound.PacketAdapter) {
 
 	go func() {
 		if loaded {
-			cond.L.Lock()
-			cond.Wait()
-			handle()
-			cond.L.Unlock()
+			if waitForNAT(key, cond) {
+				handle()
+			}
 			return
 		}
 
@@ -218,25 +395,10 @@ func handleUDPConn(packet *inbound.PacketAdapter) {
 			cond.Broadcast()
 		}()
 
-		pCtx := icontext.NewPacketConnContext(metadata)
-		proxy, rule, err := resolveMetadata(pCtx, metadata)
-		if err != nil {
-			log.Warnln("[UDP] Parse metadata failed: %s", err.Error())
-			return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), C.DefaultUDPTimeout)
-		defer cancel()
-		rawPc, err := proxy.ListenPacketContext(ctx, metadata)
+		proxy, rule, rawPc, err := dialUDP(metadata)
 		if err != nil {
-			if rule == nil {
-				log.Warnln("[UDP] dial %s to %s error: %s", proxy.Name(), metadata.RemoteAddress(), err.Error())
-			} else {
-				log.Warnln("[UDP] dial %s (match %s/%s) to %s error: %s", proxy.Name(), rule.RuleType().String(), rule.Payload(), metadata.RemoteAddress(), err.Error())
-			}
 			return
 		}
-		pCtx.InjectPacketConn(rawPc)
 		pc := statistic.NewUDPTracker(rawPc, statistic.DefaultManager, metadata, rule)
 
 		switch true {
@@ -271,6 +433,12 @@ func handleTCPConn(connCtx C.ConnContext) {
 		return
 	}
 
+	if snifferDispatcher != nil {
+		if sniffed := snifferDispatcher.TCPSniff(connCtx.Conn(), metadata); sniffed != connCtx.Conn() {
+			connCtx = icontext.NewConnContext(sniffed, metadata)
+		}
+	}
+
 	proxy, rule, err := resolveMetadata(connCtx, metadata)
 	if err != nil {
 		log.Warnln("[Metadata] parse failed: %s", err.Error())
@@ -310,19 +478,81 @@ func shouldResolveIP(rule C.Rule, metadata *C.Metadata) bool {
 }
 
 func match(metadata *C.Metadata) (C.Proxy, C.Rule, error) {
+	return matchExcluding(metadata, nil)
+}
+
+// matchExcluding is match, except any rule whose matched adapter's name
+// is in exclude is skipped over as though it hadn't matched - used by
+// handleUDPConn's fallback path to keep walking the rule list past a
+// proxy whose dial already failed. A nil exclude behaves exactly like
+// match.
+func matchExcluding(metadata *C.Metadata, exclude map[string]bool) (C.Proxy, C.Rule, error) {
 	configMux.RLock()
 	defer configMux.RUnlock()
 
 	var resolved bool
 
+	ruleGroups := [][]C.Rule{rules}
+	if metadata.PreferRulesName != "" {
+		if preferred, ok := subRules[metadata.PreferRulesName]; ok {
+			// evaluate the listener's own sub-rules ahead of the main
+			// rule list, falling through to it on no match
+			ruleGroups = [][]C.Rule{preferred, rules}
+		}
+	}
+
 	if node := resolver.DefaultHosts.Search(metadata.Host); node != nil {
 		ip := node.Data.(net.IP)
 		metadata.DstIP = ip
 		resolved = true
 	}
 
-	for _, rule := range rules {
-		if !resolved && shouldResolveIP(rule, metadata) {
+	if R.GetFindProcessMode() == R.FindProcessAlways && metadata.ProcessPath == "" {
+		metadata.ProcessPath = R.ResolveProcessPath(metadata)
+	}
+
+	visited := map[string]bool{}
+	for _, group := range ruleGroups {
+		if proxy, rule, ok := matchRuleGroup(group, metadata, &resolved, visited, exclude); ok {
+			return proxy, rule, nil
+		}
+	}
+
+	if direct, ok := proxies["DIRECT"]; !ok || !exclude[direct.Name()] {
+		return proxies["DIRECT"], nil, nil
+	}
+	return nil, nil, errNoFallbackMatch
+}
+
+// errNoFallbackMatch is returned by matchExcluding when every rule (and
+// even DIRECT) is in exclude already - there's nothing left to fall back
+// to.
+var errNoFallbackMatch = fmt.Errorf("no further rule to fall back to")
+
+// matchRuleGroup walks a single rule group (the main rules slice, or a
+// subRules entry), recursing into C.SubRule entries via subRules itself.
+// visited is shared across the whole match() call, so a sub-rules group
+// that refers back to itself - directly, or transitively through another
+// group - is skipped on its second visit instead of recursing forever.
+func matchRuleGroup(group []C.Rule, metadata *C.Metadata, resolved *bool, visited map[string]bool, exclude map[string]bool) (C.Proxy, C.Rule, bool) {
+	for _, rule := range group {
+		if rule.RuleType() == C.SubRule {
+			name := rule.Payload()
+			if visited[name] {
+				continue
+			}
+			sub, ok := subRules[name]
+			if !ok {
+				continue
+			}
+			visited[name] = true
+			if proxy, matched, ok := matchRuleGroup(sub, metadata, resolved, visited, exclude); ok {
+				return proxy, matched, true
+			}
+			continue
+		}
+
+		if !*resolved && shouldResolveIP(rule, metadata) {
 			ip, err := resolver.ResolveIP(metadata.Host)
 			if err != nil {
 				log.Debugln("[DNS] resolve %s error: %s", metadata.Host, err.Error())
@@ -330,7 +560,7 @@ func match(metadata *C.Metadata) (C.Proxy, C.Rule, error) {
 				log.Debugln("[DNS] %s --> %s", metadata.Host, ip.String())
 				metadata.DstIP = ip
 			}
-			resolved = true
+			*resolved = true
 		}
 
 		if rule.Match(metadata) {
@@ -339,13 +569,17 @@ func match(metadata *C.Metadata) (C.Proxy, C.Rule, error) {
 				continue
 			}
 
+			if exclude[adapter.Name()] {
+				continue
+			}
+
 			if metadata.NetWork == C.UDP && !adapter.SupportUDP() {
 				log.Debugln("%s UDP is not supported", adapter.Name())
 				continue
 			}
-			return adapter, rule, nil
+			return adapter, rule, true
 		}
 	}
 
-	return proxies["DIRECT"], nil, nil
+	return nil, nil, false
 }